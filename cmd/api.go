@@ -16,16 +16,23 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"html/template"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/handlers"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
+	"github.com/go-phie/gophie/downloader"
 	"github.com/go-phie/gophie/engine"
 )
 
@@ -53,6 +60,23 @@ func getDefaultsMiddleware(handler http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// Map errors from engines to the matching http status
+func engineErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, engine.ErrNoResults):
+		return http.StatusNotFound
+	case errors.Is(err, engine.ErrBlockedByCloudflare):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, engine.ErrEngineUnreachable),
+		errors.Is(err, engine.ErrLayoutChanged):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // DocHandler : renders iframe pointing to hosted docs
 func DocHandler(w http.ResponseWriter, r *http.Request) {
 	enableCors(&w)
@@ -91,11 +115,24 @@ func ListHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	result := site.List(pageNum)
+	// Scraping stops once the client disconnects
+	ctx, cancel := withTimeout(r.Context())
+	defer cancel()
+	result, err := site.List(ctx, pageNum)
+	if errors.Is(err, context.Canceled) {
+		log.Debugf("List cancelled for engine=%s", site)
+		return
+	}
+	if err != nil {
+		log.Errorf("List failed for engine=%s: %v", site, err)
+		http.Error(w, err.Error(), engineErrorStatus(err))
+		return
+	}
 	b, err := json.Marshal(result.Movies)
 	if err != nil {
-		log.Fatal("failed to serialize response: ", err)
+		log.Error("failed to serialize response: ", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 	w.Header().Add("Content-Type", "application/json")
 	w.Write(b)
@@ -125,13 +162,30 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if strings.ToLower(r.URL.Query().Get("engine")) == "all" {
+		searchAllHandler(w, r, query, pageNum)
+		return
+	}
+
 	site, err = engine.GetEngine(r.URL.Query().Get("engine"))
 	if err != nil {
 		http.Error(w, "Invalid Engine Param", http.StatusBadRequest)
 		return
 	}
 	log.Infof("Processing search Request for engine=%s and query=%s", site, query)
-	result = site.Search(query, strconv.Itoa(pageNum))
+	// Scraping stops once the client disconnects
+	ctx, cancel := withTimeout(r.Context())
+	defer cancel()
+	result, err = site.Search(ctx, query, strconv.Itoa(pageNum))
+	if errors.Is(err, context.Canceled) {
+		log.Debugf("Search cancelled for engine=%s and query=%s", site, query)
+		return
+	}
+	if err != nil {
+		log.Errorf("Search failed for engine=%s and query=%s: %v", site, query, err)
+		http.Error(w, err.Error(), engineErrorStatus(err))
+		return
+	}
 
 	// dump results
 	b, err := json.Marshal(result.Movies)
@@ -144,6 +198,27 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 	log.Debug("Completed search for ", query)
 }
 
+// Search all engines and return the merged results along with the engines that failed
+// The results are sent with the status of the error when no engine returned any movie
+func searchAllHandler(w http.ResponseWriter, r *http.Request, query string, pageNum int) {
+	log.Infof("Processing search Request for all engines and query=%s", query)
+	ctx, cancel := withTimeout(r.Context())
+	defer cancel()
+	result, err := engine.SearchAll(ctx, engine.GetEngines(), viper.GetDuration("engine-timeout"), query, strconv.Itoa(pageNum))
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		log.Debugf("Search cancelled for all engines and query=%s", query)
+		return
+	}
+	status := http.StatusOK
+	if err != nil && len(result.Movies) == 0 {
+		log.Errorf("Search failed for all engines and query=%s: %v", query, err)
+		status = engineErrorStatus(err)
+	}
+	// Failures are part of the result so that clients know which engines failed
+	writeJSON(w, status, &result)
+	log.Debug("Completed search for ", query)
+}
+
 // EngineHandler : handles Engine Listing
 func EngineHandler(w http.ResponseWriter, r *http.Request) {
 	eng := r.URL.Query().Get("engine")
@@ -176,16 +251,68 @@ func EngineHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(response)
 }
 
+// EngineHealthHandler : handles health checks of all engines or the engines given by the engine param
+func EngineHealthHandler(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Add("Content-Type", "application/json")
+	engines := engine.GetEngines()
+	if names := r.URL.Query()["engine"]; len(names) > 0 {
+		engines = map[string]engine.Engine{}
+		for _, name := range names {
+			site, err := engine.GetEngine(name)
+			if err != nil {
+				http.Error(w, "Invalid Engine Param", http.StatusBadRequest)
+				return
+			}
+			engines[strings.ToLower(name)] = site
+		}
+	}
+
+	ctx, cancel := withTimeout(r.Context())
+	defer cancel()
+	health := engine.CheckEngines(ctx, engines, viper.GetDuration("engine-timeout"))
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		log.Debug("Health check cancelled")
+		return
+	}
+	response, err := json.Marshal(health)
+	if err != nil {
+		log.Error("failed to serialize response: ", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Write(response)
+}
+
 // apiCmd represents the api command
 var apiCmd = &cobra.Command{
 	Use:   "api",
 	Short: "host gophie as an API on a PORT env variable, fallback to set argument",
 	Long:  ``,
 	Run: func(cmd *cobra.Command, args []string) {
+		// Movies queued through the API are downloaded by the server
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		manager := downloader.NewManager(downloader.DefaultStore(), viper.GetInt("parallel-downloads"))
+		served := make(chan struct{})
+		go func() {
+			defer close(served)
+			manager.Serve(ctx)
+		}()
+
 		r := http.NewServeMux()
 		r.HandleFunc("/search", getDefaultsMiddleware(SearchHandler))
 		r.HandleFunc("/list", getDefaultsMiddleware(ListHandler))
+		r.HandleFunc("/search/stream", getDefaultsMiddleware(SearchStreamHandler))
+		r.HandleFunc("/list/stream", getDefaultsMiddleware(ListStreamHandler))
 		r.HandleFunc("/engine", EngineHandler)
+		r.HandleFunc("/engine/health", EngineHealthHandler)
+		r.HandleFunc("/downloads", DownloadsHandler(manager))
+		r.HandleFunc("/downloads/", DownloadsHandler(manager))
+		// Downloads can be streamed by their id too, from disk while they are downloaded
+		proxy := downloader.NewProxy(manager)
+		r.HandleFunc("/stream", StreamHandler(proxy))
+		r.HandleFunc("/stream/", StreamHandler(proxy))
 		r.HandleFunc("/", DocHandler)
 
 		log.Info("listening on ", port)
@@ -194,7 +321,21 @@ var apiCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 		loggedRouter := handlers.LoggingHandler(os.Stdout, r)
-		log.Fatal(http.ListenAndServe(":"+port, loggedRouter))
+		// Requests are cancelled on interrupt so that event streams do not hold up the shutdown
+		server := &http.Server{
+			Addr:        ":" + port,
+			Handler:     loggedRouter,
+			BaseContext: func(net.Listener) context.Context { return ctx },
+		}
+		go func() {
+			<-ctx.Done()
+			server.Shutdown(context.Background())
+		}()
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+		// Interrupted downloads are queued again before exiting
+		<-served
 	},
 }
 
@@ -0,0 +1,303 @@
+/*
+Copyright © 2020 Bisoncorps
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-phie/gophie/downloader"
+	"github.com/go-phie/gophie/engine"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// DownloadRequest : a movie to download from an engine by its title, or a link to download
+type DownloadRequest struct {
+	Engine    string // Engine to search for the movie
+	Query     string // Search for the movie, its title if empty
+	Title     string // Title of the movie, or of the file when downloading a link
+	Link      string // Link to download directly instead of searching an engine
+	Episodes  string // Episodes of a series e.g 3-8, or all
+	Quality   string // Preferred quality of movies available in several e.g 720p
+	MaxSize   string // Largest file to download e.g 1.5GB
+	Subtitles bool   // Download subtitles next to the movie when available
+	Force     bool   // Download the movie again if it is already downloaded
+	// Headers sent with requests for the file, e.g the Referer or Cookie its file host expects
+	Headers map[string]string
+}
+
+// downloadJSON : a job of the download queue with its progress
+type downloadJSON struct {
+	downloader.Job
+	Progress downloader.Progress
+}
+
+// downloadStatus : the http status of the errors of the download queue
+func downloadStatus(err error) int {
+	switch {
+	case errors.Is(err, downloader.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, downloader.ErrAlreadyDownloaded):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON : write v as the JSON response with status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Error("failed to serialize response: ", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// DownloadsHandler : handles the download queue, whose jobs are downloaded by manager
+//
+//	GET    /downloads              all downloads and their progress
+//	POST   /downloads              queue a movie, or a link, from a DownloadRequest
+//	GET    /downloads/events       server-sent events of the progress of every download
+//	GET    /downloads/{id}         a download and its progress
+//	DELETE /downloads/{id}         cancel a download and remove it, with its files if delete_files=true
+//	POST   /downloads/{id}/pause   pause a download
+//	POST   /downloads/{id}/resume  resume a paused or failed download
+func DownloadsHandler(manager *downloader.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enableCors(&w)
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/downloads"), "/"), "/")
+		switch {
+		case parts[0] == "" && r.Method == http.MethodGet:
+			listDownloads(w, manager)
+		case parts[0] == "" && r.Method == http.MethodPost:
+			addDownload(w, r, manager)
+		case len(parts) == 1 && parts[0] == "events" && r.Method == http.MethodGet:
+			streamDownloads(w, r, manager)
+		case len(parts) == 1 && r.Method == http.MethodGet:
+			getDownload(w, manager, parts[0])
+		case len(parts) == 1 && r.Method == http.MethodDelete:
+			removeDownload(w, r, manager, parts[0])
+		case len(parts) == 2 && r.Method == http.MethodPost && (parts[1] == "pause" || parts[1] == "resume"):
+			changeDownload(w, manager, parts[0], parts[1])
+		default:
+			http.Error(w, "Not Found", http.StatusNotFound)
+		}
+	}
+}
+
+// listDownloads : respond with every download and its progress
+func listDownloads(w http.ResponseWriter, manager *downloader.Manager) {
+	jobs, err := manager.Store().List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	downloads := []downloadJSON{}
+	for _, job := range jobs {
+		downloads = append(downloads, downloadJSON{Job: job, Progress: manager.Progress(job)})
+	}
+	writeJSON(w, http.StatusOK, downloads)
+}
+
+// getDownload : respond with the download with id and its progress
+func getDownload(w http.ResponseWriter, manager *downloader.Manager, id string) {
+	job, err := manager.Store().Get(id)
+	if err != nil {
+		http.Error(w, err.Error(), downloadStatus(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, &downloadJSON{Job: job, Progress: manager.Progress(job)})
+}
+
+// removeDownload : cancel the download with id and remove it from the queue
+func removeDownload(w http.ResponseWriter, r *http.Request, manager *downloader.Manager, id string) {
+	job, err := manager.Store().Get(id)
+	if err == nil {
+		err = manager.Remove(id)
+	}
+	if err == nil && r.URL.Query().Get("delete_files") == "true" {
+		err = job.RemoveFiles()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), downloadStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// changeDownload : pause or resume the download with id
+func changeDownload(w http.ResponseWriter, manager *downloader.Manager, id, action string) {
+	var err error
+	if action == "pause" {
+		err = manager.Pause(id)
+	} else {
+		err = manager.Resume(id)
+	}
+	if err != nil {
+		status := downloadStatus(err)
+		if status == http.StatusInternalServerError {
+			// The download cannot be paused in its state e.g once it is completed
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	getDownload(w, manager, id)
+}
+
+// addDownload : queue the movie or link of the DownloadRequest in the body
+func addDownload(w http.ResponseWriter, r *http.Request, manager *downloader.Manager) {
+	var req DownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid download request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := withTimeout(r.Context())
+	defer cancel()
+	jobs, status, err := downloadJobs(ctx, req)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	queued := []downloadJSON{}
+	for _, job := range jobs {
+		job.Subtitles = req.Subtitles
+		job.Headers = req.Headers
+		job, err = manager.Queue(r.Context(), job, req.Force)
+		if errors.Is(err, downloader.ErrAlreadyDownloaded) && len(jobs) > 1 {
+			// Episodes already downloaded are skipped
+			continue
+		}
+		if err != nil {
+			http.Error(w, err.Error(), downloadStatus(err))
+			return
+		}
+		queued = append(queued, downloadJSON{Job: job, Progress: job.Progress()})
+	}
+	writeJSON(w, http.StatusCreated, queued)
+}
+
+// publicLink : check that a link given by a caller is on a public address, replaced by tests
+// whose files are served locally
+var publicLink = downloader.PublicLink
+
+// downloadJobs : the jobs to download the movie or the link of req, or the http status of the error
+func downloadJobs(ctx context.Context, req DownloadRequest) ([]downloader.Job, int, error) {
+	outputDir := viper.GetString("output-dir")
+	if req.Link != "" {
+		link, err := url.Parse(req.Link)
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid link %q", req.Link)
+		}
+		if err = publicLink(ctx, link); errors.Is(err, downloader.ErrPrivateAddress) {
+			return nil, http.StatusForbidden, err
+		} else if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid link %q: %v", req.Link, err)
+		}
+		title := req.Title
+		if title == "" {
+			title = strings.TrimSuffix(path.Base(link.Path), path.Ext(link.Path))
+		}
+		return []downloader.Job{downloader.NewJob(&engine.Movie{Title: title, DownloadLink: link}, outputDir)}, 0, nil
+	}
+	if req.Engine == "" || req.Title == "" {
+		return nil, http.StatusBadRequest, errors.New("engine and title, or link, must be set")
+	}
+	site, err := engine.GetEngine(req.Engine)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	var maxSize int64
+	if req.MaxSize != "" {
+		if maxSize = engine.ParseSize(req.MaxSize); maxSize <= 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid max size %q, expected e.g 700MB or 1.5GB", req.MaxSize)
+		}
+	}
+	query := req.Query
+	if query == "" {
+		query = req.Title
+	}
+	result, err := site.Search(ctx, query)
+	if err != nil {
+		return nil, engineErrorStatus(err), err
+	}
+	movie, err := pickMovie(result, req.Title)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	// The file of the movie or of every episode in the wanted quality and size
+	pick := func(variants []engine.Variant) (engine.Variant, error) {
+		if req.Quality == "" && maxSize == 0 {
+			return variants[0], nil
+		}
+		return engine.SelectVariant(variants, req.Quality, maxSize)
+	}
+
+	episodes := movie.Episodes()
+	if !hasEpisodes(&movie, episodes) {
+		variant, err := pick(movieVariants(&movie))
+		if err != nil || variant.Link == nil {
+			return nil, http.StatusNotFound, fmt.Errorf("%s has no file to download", movie.Title)
+		}
+		movie.DownloadLink = variant.Link
+		return []downloader.Job{downloader.NewJob(&movie, outputDir)}, 0, nil
+	}
+	switch req.Episodes {
+	case "":
+		return nil, http.StatusBadRequest, fmt.Errorf("%s is a series, set episodes e.g 3-8 or all", movie.Title)
+	case "all":
+	default:
+		if episodes, err = filterEpisodes(episodes, req.Episodes); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+	var jobs []downloader.Job
+	for _, episode := range episodes {
+		variants := episode.Variants
+		if len(variants) == 0 {
+			variants = []engine.Variant{{Size: episode.Size, Link: episode.Link}}
+		}
+		variant, err := pick(variants)
+		if err != nil || variant.Link == nil {
+			log.Warnf("Skipping %s %s: no file to download", movie.Title, episode.Code())
+			continue
+		}
+		episode.Link = variant.Link
+		jobs = append(jobs, downloader.NewEpisodeJob(&movie, episode, outputDir))
+	}
+	if len(jobs) == 0 {
+		return nil, http.StatusNotFound, fmt.Errorf("%s has no episodes to download in %s", movie.Title, req.Episodes)
+	}
+	return jobs, 0, nil
+}
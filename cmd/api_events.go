@@ -0,0 +1,233 @@
+/*
+Copyright © 2020 Bisoncorps
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-phie/gophie/downloader"
+	"github.com/go-phie/gophie/engine"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Interval of the comments sent on idle event streams so that proxies do not close them
+const heartbeatInterval = 15 * time.Second
+
+// eventStream : a response of server-sent events, flushed to the client as each event is sent
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newEventStream : start a stream of server-sent events as the response to w
+func newEventStream(w http.ResponseWriter) (*eventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming is not supported by the server")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stops proxies such as nginx from buffering the events
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &eventStream{w: w, flusher: flusher}, nil
+}
+
+// send : send v as JSON in an event named event
+func (s *eventStream) send(event string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// comment : send a comment, ignored by clients, to keep the stream open
+func (s *eventStream) comment(text string) error {
+	if _, err := fmt.Fprintf(s.w, ": %s\n\n", text); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// streamError : data of the error event ending a stream of movies
+type streamError struct {
+	Error  string
+	Status int // Status the same request would have failed with on the endpoint that does not stream
+}
+
+// SearchStreamHandler : handles search requests like SearchHandler, but streams every movie as an
+// event as soon as its download links are resolved, rather than once every movie is
+//
+//	event: movie  a movie of the results
+//	event: done   the whole result as returned by /search, once the search is over
+//	event: error  the search failed, with the error and its http status
+func SearchStreamHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("query")
+	if query == "" {
+		http.Error(w, "Query param must be added to url", http.StatusBadRequest)
+		return
+	}
+	page := r.URL.Query().Get("page")
+	if page == "" {
+		page = "1"
+	} else if _, err := strconv.Atoi(page); err != nil {
+		http.Error(w, "Page must be a number", http.StatusBadRequest)
+		return
+	}
+
+	if strings.ToLower(r.URL.Query().Get("engine")) == "all" {
+		log.Infof("Processing search stream for all engines and query=%s", query)
+		streamMovies(w, r, func(ctx context.Context) (interface{}, error) {
+			result, err := engine.SearchAll(ctx, engine.GetEngines(), viper.GetDuration("engine-timeout"), query, page)
+			if err != nil && len(result.Movies) == 0 {
+				return nil, err
+			}
+			// Failures are part of the result like on /search
+			return &result, nil
+		})
+		return
+	}
+
+	site, err := engine.GetEngine(r.URL.Query().Get("engine"))
+	if err != nil {
+		http.Error(w, "Invalid Engine Param", http.StatusBadRequest)
+		return
+	}
+	log.Infof("Processing search stream for engine=%s and query=%s", site, query)
+	streamMovies(w, r, func(ctx context.Context) (interface{}, error) {
+		result, err := site.Search(ctx, query, page)
+		return result.Movies, err
+	})
+}
+
+// ListStreamHandler : handles list requests like ListHandler, but streams every movie as an event
+// as soon as its download links are resolved, with the same events as SearchStreamHandler
+func ListStreamHandler(w http.ResponseWriter, r *http.Request) {
+	site, err := engine.GetEngine(r.URL.Query().Get("engine"))
+	if err != nil {
+		http.Error(w, "Invalid Engine Param", http.StatusBadRequest)
+		return
+	}
+	page := 1
+	if r.URL.Query().Get("page") != "" {
+		if page, err = strconv.Atoi(r.URL.Query().Get("page")); err != nil {
+			http.Error(w, "Page must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+	streamMovies(w, r, func(ctx context.Context) (interface{}, error) {
+		result, err := site.List(ctx, page)
+		return result.Movies, err
+	})
+}
+
+// streamMovies : stream the movies found by fetch as events, then its result once it returns
+// The movies of engines that do not scrape pages in turn are only sent in the final result
+func streamMovies(w http.ResponseWriter, r *http.Request, fetch func(ctx context.Context) (interface{}, error)) {
+	stream, err := newEventStream(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Scraping stops once the client disconnects
+	ctx, cancel := withTimeout(r.Context())
+	defer cancel()
+	// Engines searched at once find movies from their own goroutines
+	var mu sync.Mutex
+	ctx = engine.WithMovieFound(ctx, func(movie engine.Movie) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := stream.send("movie", &movie); err != nil {
+			log.Debugf("Could not stream %s: %v", movie.Title, err)
+			cancel()
+		}
+	})
+
+	result, err := fetch(ctx)
+	mu.Lock()
+	defer mu.Unlock()
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		log.Debug("Stream cancelled")
+		return
+	}
+	if err != nil {
+		log.Errorf("Stream failed: %v", err)
+		stream.send("error", streamError{Error: err.Error(), Status: engineErrorStatus(err)})
+		return
+	}
+	stream.send("done", result)
+}
+
+// streamDownloads : stream the progress of every download, starting with the progress of each
+// download in the queue, until the client disconnects
+func streamDownloads(w http.ResponseWriter, r *http.Request, manager *downloader.Manager) {
+	// Subscribed before listing the queue so that no change is missed in between
+	events, unsubscribe := manager.Subscribe()
+	defer unsubscribe()
+	jobs, err := manager.Store().List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stream, err := newEventStream(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, job := range jobs {
+		progress := manager.Progress(job)
+		if err = stream.send("progress", &progress); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case progress, ok := <-events:
+			if !ok {
+				return
+			}
+			err = stream.send("progress", &progress)
+		case <-heartbeat.C:
+			err = stream.comment("ping")
+		}
+		if err != nil {
+			log.Debugf("Download events stopped: %v", err)
+			return
+		}
+	}
+}
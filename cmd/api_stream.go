@@ -0,0 +1,92 @@
+/*
+Copyright © 2020 Bisoncorps
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-phie/gophie/downloader"
+)
+
+// streamJSON : a movie or episode served by the proxy and the url to stream it from
+type streamJSON struct {
+	ID    string
+	Title string
+	URL   string // Stable url of the stream on the API, which players can seek through
+}
+
+// StreamHandler : handles streams of movies through proxy, which serves them from their links with
+// the headers their file hosts expect, or from disk once they are downloaded
+//
+//	POST /stream       serve a movie, or a link, from a DownloadRequest at a stable url
+//	GET  /stream/{id}  the file of a stream, or of a download, with range requests
+func StreamHandler(proxy *downloader.Proxy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enableCors(&w)
+		// Players and browsers seek with range requests, and need to read the range they got back
+		w.Header().Set("Access-Control-Expose-Headers", "Accept-Ranges, Content-Length, Content-Range")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Range")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/stream"), "/")
+		switch {
+		case id == "" && r.Method == http.MethodPost:
+			addStream(w, r, proxy)
+		case id != "" && !strings.Contains(id, "/") && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+			proxy.ServeJob(w, r, id)
+		default:
+			http.Error(w, "Not Found", http.StatusNotFound)
+		}
+	}
+}
+
+// addStream : serve the movie or link of the DownloadRequest in the body, responding with the url
+// of every stream
+func addStream(w http.ResponseWriter, r *http.Request, proxy *downloader.Proxy) {
+	var req DownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid stream request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := withTimeout(r.Context())
+	defer cancel()
+	jobs, status, err := downloadJobs(ctx, req)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	streams := []streamJSON{}
+	for _, job := range jobs {
+		// Headers of the request are not sent, so that the proxy cannot be used to forge requests
+		job = proxy.Add(job)
+		streams = append(streams, streamJSON{
+			ID:    job.ID,
+			Title: job.Title,
+			URL:   fmt.Sprintf("%s://%s/stream/%s", scheme, r.Host, job.ID),
+		})
+	}
+	writeJSON(w, http.StatusCreated, streams)
+}
@@ -1,27 +1,412 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-phie/gophie/downloader"
+	"github.com/go-phie/gophie/engine"
+	"github.com/go-phie/gophie/transport"
+	"github.com/spf13/viper"
 )
 
+// replayFixtures : answer the requests of engines with the pages recorded for name until the
+// returned function is called, and the query the pages were recorded for
+func replayFixtures(t *testing.T, name string) (string, func()) {
+	dir := filepath.Join("..", "engine", "testdata", "fixtures", name)
+	query, err := engine.FixtureQuery(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := engine.Transport
+	engine.Transport = transport.NewReplayTransport(filepath.Join(dir, "pages"))
+	return query, func() { engine.Transport = previous }
+}
+
 func TestSearchAPI(t *testing.T) {
+	query, restore := replayFixtures(t, "mycoolmoviez")
+	defer restore()
 	ts := httptest.NewServer(http.HandlerFunc(SearchHandler))
 	defer ts.Close()
 
-	res, _ := http.Get(ts.URL + "?query=good+boys&engine=mycoolmoviez")
-	if res.StatusCode != 200 {
-		t.Errorf("Server failing")
+	res, err := http.Get(ts.URL + "?query=" + url.QueryEscape(query) + "&engine=mycoolmoviez")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var movies []map[string]interface{}
+	json.NewDecoder(res.Body).Decode(&movies)
+	if res.StatusCode != 200 || len(movies) == 0 {
+		t.Errorf("Server failing, got %s with %d movies", res.Status, len(movies))
 	}
 }
 
 func TestListAPI(t *testing.T) {
+	_, restore := replayFixtures(t, "fzmovies")
+	defer restore()
 	ts := httptest.NewServer(http.HandlerFunc(ListHandler))
 	defer ts.Close()
 
-	res, _ := http.Get(ts.URL + "?page=1&engine=fzmovies")
+	res, err := http.Get(ts.URL + "?page=1&engine=fzmovies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var movies []map[string]interface{}
+	json.NewDecoder(res.Body).Decode(&movies)
+	if res.StatusCode != 200 || len(movies) == 0 {
+		t.Errorf("Server failing, got %s with %d movies", res.Status, len(movies))
+	}
+}
+
+func TestEngineErrorStatus(t *testing.T) {
+	cases := map[error]int{
+		&engine.EngineError{Engine: "NetNaija", Kind: engine.ErrNoResults}:                                        http.StatusNotFound,
+		&engine.EngineError{Engine: "NetNaija", Kind: engine.ErrBlockedByCloudflare}:                              http.StatusServiceUnavailable,
+		&engine.EngineError{Engine: "NetNaija", Kind: engine.ErrEngineUnreachable}:                                http.StatusBadGateway,
+		&engine.EngineError{Engine: "NetNaija", Kind: engine.ErrLayoutChanged}:                                    http.StatusBadGateway,
+		&engine.EngineError{Engine: "NetNaija", Kind: engine.ErrEngineUnreachable, Err: context.DeadlineExceeded}: http.StatusGatewayTimeout,
+	}
+	for err, status := range cases {
+		if got := engineErrorStatus(err); got != status {
+			t.Errorf("%v: expected status %d, got %d", err, status, got)
+		}
+	}
+}
+
+func TestSearchAllAPI(t *testing.T) {
+	// Only fzmovies has pages recorded, so every other engine fails
+	_, restore := replayFixtures(t, "fzmovies")
+	defer restore()
+	ts := httptest.NewServer(http.HandlerFunc(SearchHandler))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "?query=jumanji&engine=all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("Expected the movies of fzmovies, got %s", res.Status)
+	}
+	var result struct {
+		Movies []map[string]interface{}
+		Failed map[string]string
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Movies) == 0 || len(result.Failed) == 0 {
+		t.Errorf("Expected movies and failed engines in response, got %+v", result)
+	}
+
+	res, err = http.Get(ts.URL + "?query=unrecorded&engine=all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected bad gateway when every engine fails, got %s", res.Status)
+	}
+}
+
+func TestEngineHealthAPI(t *testing.T) {
+	// Replay the pages recorded for the engine tests
+	defer func(previous http.RoundTripper) { engine.Transport = previous }(engine.Transport)
+	engine.Transport = transport.NewReplayTransport(filepath.Join("..", "engine", "testdata", "fixtures", "fzmovies", "pages"))
+	ts := httptest.NewServer(http.HandlerFunc(EngineHealthHandler))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "?engine=fzmovies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
 	if res.StatusCode != 200 {
-		t.Errorf("Server failing")
+		t.Fatalf("Server failing")
+	}
+	var health []struct {
+		Engine string
+		Status string
+		Search struct{ Movies, Resolved int }
+	}
+	if err := json.NewDecoder(res.Body).Decode(&health); err != nil {
+		t.Fatal(err)
+	}
+	if len(health) != 1 || health[0].Engine != "fzmovies" || health[0].Status != engine.HealthOK || health[0].Search.Resolved != 2 {
+		t.Errorf("Unexpected health %+v", health)
+	}
+
+	res, err = http.Get(ts.URL + "?engine=unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected bad request for unknown engine, got %s", res.Status)
+	}
+}
+
+// allowLocalLinks : let the API fetch the links of local test servers until the returned function is called
+func allowLocalLinks() func() {
+	previous := publicLink
+	publicLink = func(context.Context, *url.URL) error { return nil }
+	return func() { publicLink = previous }
+}
+
+func TestDownloadsAPI(t *testing.T) {
+	content := []byte("the whole movie")
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "movie.mp4", time.Time{}, bytes.NewReader(content))
+	}))
+	defer files.Close()
+	defer func(previous string) { viper.Set("output-dir", previous) }(viper.GetString("output-dir"))
+	viper.Set("output-dir", t.TempDir())
+	manager := downloader.NewManager(downloader.NewStore(filepath.Join(t.TempDir(), "downloads.json")), 1)
+	ts := httptest.NewServer(DownloadsHandler(manager))
+	defer ts.Close()
+
+	request := func(method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	// Links on the network of the API are refused
+	for _, link := range []string{"http://127.0.0.1/movie.mp4", "http://10.0.0.1/movie.mp4", "http://[::1]:8080/movie.mp4"} {
+		res := request(http.MethodPost, "/downloads", `{"Link": "`+link+`"}`)
+		res.Body.Close()
+		if res.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected %s to be refused, got %s", link, res.Status)
+		}
+	}
+	defer allowLocalLinks()()
+
+	res := request(http.MethodPost, "/downloads", `{"Link": "`+files.URL+`/movie.mp4", "Title": "Movie"}`)
+	var queued []struct {
+		ID       string
+		State    string
+		Progress struct{ State string }
+	}
+	json.NewDecoder(res.Body).Decode(&queued)
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated || len(queued) != 1 || queued[0].Progress.State != "queued" {
+		t.Fatalf("Expected the link to be queued, got %s %+v", res.Status, queued)
+	}
+	manager.Run(context.Background())
+
+	res = request(http.MethodGet, "/downloads/"+queued[0].ID, "")
+	var download struct {
+		State    string
+		File     string
+		Progress struct{ Downloaded, Size int64 }
+	}
+	json.NewDecoder(res.Body).Decode(&download)
+	res.Body.Close()
+	if download.State != "completed" || download.Progress.Downloaded != int64(len(content)) {
+		t.Errorf("Expected the download to be completed, got %+v", download)
+	}
+	for _, test := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodGet, "/downloads", "", http.StatusOK},
+		{http.MethodPost, "/downloads/" + queued[0].ID + "/pause", "", http.StatusConflict},
+		{http.MethodPost, "/downloads", `{"Link": "` + files.URL + `/movie.mp4", "Title": "Movie"}`, http.StatusConflict},
+		{http.MethodPost, "/downloads", `{"Engine": "fzmovies"}`, http.StatusBadRequest},
+		{http.MethodPost, "/downloads", `{"Link": "ftp://example.com/movie.mp4"}`, http.StatusBadRequest},
+		{http.MethodGet, "/downloads/unknown", "", http.StatusNotFound},
+		{http.MethodDelete, "/downloads/" + queued[0].ID + "?delete_files=true", "", http.StatusNoContent},
+		{http.MethodDelete, "/downloads/" + queued[0].ID, "", http.StatusNotFound},
+	} {
+		res = request(test.method, test.path, test.body)
+		res.Body.Close()
+		if res.StatusCode != test.status {
+			t.Errorf("%s %s: expected status %d, got %s", test.method, test.path, test.status, res.Status)
+		}
+	}
+	if _, err := os.Stat(download.File); !os.IsNotExist(err) {
+		t.Errorf("Expected the file to be deleted, got %v", err)
+	}
+}
+
+// readEvent : the name and data of the next server-sent event of r
+func readEvent(t *testing.T, r *bufio.Reader) (event, data string) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Stream ended before the event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestSearchStreamAPI(t *testing.T) {
+	defer func(previous http.RoundTripper) { engine.Transport = previous }(engine.Transport)
+	engine.Transport = transport.NewReplayTransport(filepath.Join("..", "engine", "testdata", "fixtures", "fzmovies", "pages"))
+	ts := httptest.NewServer(http.HandlerFunc(SearchStreamHandler))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "?query=jumanji&engine=fzmovies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %s %s", res.Status, res.Header.Get("Content-Type"))
+	}
+	var streamed []string
+	body := bufio.NewReader(res.Body)
+	for {
+		event, data := readEvent(t, body)
+		if event == "movie" {
+			var movie struct{ Title, DownloadLink string }
+			json.Unmarshal([]byte(data), &movie)
+			streamed = append(streamed, movie.Title)
+			continue
+		}
+		if event != "done" {
+			t.Fatalf("Unexpected event %s: %s", event, data)
+		}
+		var movies []struct{ Title string }
+		json.Unmarshal([]byte(data), &movies)
+		if len(movies) == 0 || len(streamed) != len(movies) {
+			t.Fatalf("Expected every movie to be streamed, got %v of %v", streamed, movies)
+		}
+		for i := range movies {
+			if streamed[i] != movies[i].Title {
+				t.Errorf("Expected %s to be streamed, got %s", movies[i].Title, streamed[i])
+			}
+		}
+		return
+	}
+}
+
+func TestDownloadEventsAPI(t *testing.T) {
+	content := []byte("the whole movie")
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "movie.mp4", time.Time{}, bytes.NewReader(content))
+	}))
+	defer files.Close()
+	manager := downloader.NewManager(downloader.NewStore(filepath.Join(t.TempDir(), "downloads.json")), 1)
+	queued, err := manager.Add(downloader.Job{Title: "Movie", URL: files.URL + "/movie.mp4", Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(DownloadsHandler(manager))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/downloads/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body := bufio.NewReader(res.Body)
+	var progress struct {
+		JobID, State string
+		Downloaded   int64
+	}
+	event, data := readEvent(t, body)
+	json.Unmarshal([]byte(data), &progress)
+	if event != "progress" || progress.JobID != queued.ID || progress.State != "queued" {
+		t.Fatalf("Expected the progress of the queued download first, got %s %s", event, data)
+	}
+
+	go manager.Run(context.Background())
+	for progress.State != "completed" {
+		if _, data = readEvent(t, body); json.Unmarshal([]byte(data), &progress) != nil {
+			t.Fatalf("Invalid progress %s", data)
+		}
+		if progress.State == "failed" {
+			t.Fatalf("Expected the download to complete, got %s", data)
+		}
+	}
+	if progress.Downloaded != int64(len(content)) {
+		t.Errorf("Expected the whole file to be downloaded, got %d bytes", progress.Downloaded)
+	}
+}
+
+func TestStreamAPI(t *testing.T) {
+	content := []byte("the whole movie")
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cookie") != "" {
+			http.Error(w, "Headers of the request were sent", http.StatusBadRequest)
+			return
+		}
+		http.ServeContent(w, r, "movie.mp4", time.Time{}, bytes.NewReader(content))
+	}))
+	defer files.Close()
+	proxy := downloader.NewProxy(downloader.NewManager(downloader.NewStore(filepath.Join(t.TempDir(), "downloads.json")), 1))
+	ts := httptest.NewServer(StreamHandler(proxy))
+	defer ts.Close()
+
+	stream := `{"Link": "` + files.URL + `/movie.mp4", "Headers": {"Cookie": "session=1"}}`
+	res, err := http.Post(ts.URL+"/stream", "application/json", strings.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a link on a private address to be refused, got %s", res.Status)
+	}
+	defer allowLocalLinks()()
+
+	if res, err = http.Post(ts.URL+"/stream", "application/json", strings.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+	var streams []struct{ ID, Title, URL string }
+	json.NewDecoder(res.Body).Decode(&streams)
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated || len(streams) != 1 || streams[0].URL != ts.URL+"/stream/"+streams[0].ID {
+		t.Fatalf("Expected the link to be streamed, got %s %+v", res.Status, streams)
+	}
+
+	// The file server is on a private address, which streams do not reach
+	if res, err = http.Get(streams[0].URL); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a file on a private address to be forbidden, got %s", res.Status)
+	}
+	proxy.Client = files.Client()
+
+	req, _ := http.NewRequest(http.MethodGet, streams[0].URL, nil)
+	req.Header.Set("Range", "bytes=4-8")
+	if res, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent || string(body) != "whole" || res.Header.Get("Content-Type") != "video/mp4" {
+		t.Errorf("Expected part of the movie, got %s %s %q", res.Status, res.Header.Get("Content-Type"), body)
+	}
+	if res, err = http.Get(ts.URL + "/stream/unknown"); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected an unknown stream to be not found, got %s", res.Status)
 	}
 }
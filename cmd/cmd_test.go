@@ -1,8 +1,10 @@
 package cmd
 
 import (
-	. "github.com/smartystreets/goconvey/convey"
 	"testing"
+
+	"github.com/go-phie/gophie/engine"
+	. "github.com/smartystreets/goconvey/convey"
 )
 
 func TestCmd(t *testing.T) {
@@ -25,3 +27,27 @@ func TestCmd(t *testing.T) {
 	})
 
 }
+
+func TestPickMovie(t *testing.T) {
+	result := engine.SearchResult{Movies: []engine.Movie{
+		{Index: 0, Title: "Jumanji The Next Level"},
+		{Index: 1, Title: "Jumanji"},
+		{Index: 2, Title: "Zathura"},
+	}}
+	Convey("CLI [Test selecting movies without prompting]\n", t, func() {
+		for choice, title := range map[string]string{
+			"":        "Jumanji The Next Level",
+			"2":       "Zathura",
+			"jumanji": "Jumanji",
+			"next":    "Jumanji The Next Level",
+		} {
+			movie, err := pickMovie(result, choice)
+			So(err, ShouldBeNil)
+			So(movie.Title, ShouldEqual, title)
+		}
+		for _, choice := range []string{"3", "-1", "Dune"} {
+			_, err := pickMovie(result, choice)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+/*
+Copyright © 2020 Bisoncorps
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-phie/gophie/downloader"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var deleteFiles bool
+
+// jobStatus : the state of a job, noting completed downloads whose file is gone
+func jobStatus(job downloader.Job) string {
+	if job.State == downloader.StateCompleted && !job.Completed() {
+		return "completed, file missing"
+	}
+	return string(job.State)
+}
+
+// jobProgress : the bytes downloaded out of the size of the job
+func jobProgress(job downloader.Job) string {
+	downloaded := job.Downloaded()
+	if job.Size <= 0 {
+		return downloader.FormatBytes(downloaded)
+	}
+	return fmt.Sprintf("%s / %s", downloader.FormatBytes(downloaded), downloader.FormatBytes(job.Size))
+}
+
+// downloadsCmd represents the downloads command
+var downloadsCmd = &cobra.Command{
+	Use:   "downloads",
+	Short: "Manage the download queue",
+	Long: `Manage the downloads queued by search, list and the API
+
+		gophie downloads list (All downloads and their state)
+		gophie downloads remove [id...] (Remove downloads from the queue)
+		gophie downloads retry [id...] (Queue downloads again and download them)
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(`Download Queue
+
+	gophie downloads list - All downloads and their state
+	gophie downloads remove - Remove downloads from the queue
+	gophie downloads retry - Queue downloads again and download them`)
+	},
+}
+
+// listDownloadsCmd represents the downloads list command
+var listDownloadsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "lists all downloads and their state",
+	Run: func(cmd *cobra.Command, args []string) {
+		jobs, err := downloader.DefaultStore().List()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(jobs) == 0 {
+			log.Info("No downloads")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATE\tPROGRESS\tTITLE\tFILE")
+		for _, job := range jobs {
+			status := jobStatus(job)
+			if job.Error != "" {
+				status += ": " + job.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", job.ID, status, jobProgress(job), job.Title, job.File)
+		}
+		w.Flush()
+	},
+}
+
+// removeDownloadsCmd represents the downloads remove command
+var removeDownloadsCmd = &cobra.Command{
+	Use:   "remove [id...]",
+	Short: "Remove downloads from the queue",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager := downloader.NewManager(downloader.DefaultStore(), viper.GetInt("parallel-downloads"))
+		failed := false
+		for _, id := range args {
+			job, err := manager.Store().Get(id)
+			if err == nil {
+				err = manager.Remove(id)
+			}
+			if err == nil && deleteFiles {
+				err = job.RemoveFiles()
+			}
+			if err != nil {
+				log.Errorf("Could not remove %s: %v", id, err)
+				failed = true
+				continue
+			}
+			log.Infof("Removed %s", job.Title)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// retryDownloadsCmd represents the downloads retry command
+var retryDownloadsCmd = &cobra.Command{
+	Use:   "retry [id...]",
+	Short: "Queue failed or paused downloads again and download them",
+	Long: `Queue downloads again and download every queued download.
+Without ids, all failed downloads are retried`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manager := downloader.NewManager(downloader.DefaultStore(), viper.GetInt("parallel-downloads"))
+		if len(args) == 0 {
+			jobs, err := manager.Store().List()
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, job := range jobs {
+				if job.State == downloader.StateFailed {
+					args = append(args, job.ID)
+				}
+			}
+		}
+		for _, id := range args {
+			if err := manager.Resume(id); err != nil {
+				log.Errorf("Could not retry %s: %v", id, err)
+			}
+		}
+
+		// Interrupted downloads are queued again
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		stopProgress := downloader.ShowProgress(manager)
+		manager.Run(ctx)
+		stopProgress()
+
+		failed := []string{}
+		for _, id := range args {
+			job, err := manager.Store().Get(id)
+			if err != nil {
+				continue
+			}
+			fmt.Printf("%s: %s\n", job.Title, jobStatus(job))
+			if job.State == downloader.StateFailed {
+				failed = append(failed, job.Title)
+			}
+		}
+		if len(failed) > 0 {
+			log.Errorf("Failed to download %s", strings.Join(failed, ", "))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	removeDownloadsCmd.Flags().BoolVar(&deleteFiles, "delete-files", false, "Also delete the downloaded and partial files")
+	downloadsCmd.AddCommand(listDownloadsCmd)
+	downloadsCmd.AddCommand(removeDownloadsCmd)
+	downloadsCmd.AddCommand(retryDownloadsCmd)
+	rootCmd.AddCommand(downloadsCmd)
+}
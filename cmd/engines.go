@@ -16,12 +16,20 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/go-phie/gophie/engine"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var showEngine string
@@ -34,12 +42,16 @@ var engineCmd = &cobra.Command{
 
 		gophie engine list (All available engines)
 		gophie engine show (Details about a particular engine)
+		gophie engine check (Check the health of engines)
+		gophie engine record (Record fixtures for the tests of an engine)
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println(`Engines Summaries and List
 
 	gophie engine list - All available engines
-	gophie engine show - Details about a particular engine`)
+	gophie engine show - Details about a particular engine
+	gophie engine check - Check the health of engines
+	gophie engine record - Record fixtures for the tests of an engine`)
 	},
 }
 
@@ -49,8 +61,9 @@ var listEngineCmd = &cobra.Command{
 	Short: "lists all available engines",
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Available Engines")
-		for k, v := range engine.GetEngines() {
-			fmt.Printf("\t%s: %s\n", k, v)
+		engines := engine.GetEngines()
+		for _, name := range engine.EngineNames() {
+			fmt.Printf("\t%s: %s\n", name, engines[name])
 		}
 	},
 }
@@ -73,7 +86,122 @@ var showEngineCmd = &cobra.Command{
 	},
 }
 
+// recordEngineCmd represents the engine record command
+var recordEngineCmd = &cobra.Command{
+	Use:   "record [engine]",
+	Short: "Record the pages of an engine as fixtures for the engine tests",
+	Long: `Search and list an engine, saving every page retrieved and the movies returned
+to the fixtures the engine tests replay. Run from the root of the repository
+
+	gophie engines record fzmovies
+	gophie engines record tvseries --query devs
+	`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		e, err := engine.GetEngine(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		dir := filepath.Join(fixturesDir, strings.ToLower(args[0]))
+		query := recordQuery
+		if query == "" {
+			// Record the same query again when refreshing fixtures
+			if query, err = engine.FixtureQuery(dir); err != nil {
+				query = "jumanji"
+			}
+		}
+		// Cached pages would not go through the recorder
+		viper.Set("ignore-cache", true)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ctx, cancel := withTimeout(ctx)
+		defer cancel()
+		if err = engine.RecordFixtures(ctx, e, dir, query); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Recorded %s fixtures for %q to %s\n", e, query, dir)
+	},
+}
+
+// checkEngineCmd represents the engine check command
+var checkEngineCmd = &cobra.Command{
+	Use:   "check [engine...]",
+	Short: "Check the health of engines",
+	Long: `Run a canary search and list on engines and report whether movies were returned
+and their download links resolved to media files. All engines are checked if none is given.
+Exits with status 1 when any engine is down
+
+	gophie engines check
+	gophie engines check fzmovies netnaija --json
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		engines := engine.GetEngines()
+		if len(args) > 0 {
+			engines = map[string]engine.Engine{}
+			for _, name := range args {
+				e, err := engine.GetEngine(name)
+				if err != nil {
+					log.Fatal(err)
+				}
+				engines[strings.ToLower(name)] = e
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ctx, cancel := withTimeout(ctx)
+		defer cancel()
+		stopSpinner := startSpinner("Checking engines...")
+		health := engine.CheckEngines(ctx, engines, viper.GetDuration("engine-timeout"))
+		stopSpinner()
+
+		if checkJSON {
+			b, err := json.MarshalIndent(health, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(b))
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ENGINE\tSTATUS\tSEARCH\tLIST")
+			for _, h := range health {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", h.Engine, h.Status, formatCanary(h.Search), formatCanary(h.List))
+			}
+			w.Flush()
+		}
+		for _, h := range health {
+			if h.Status == engine.HealthDown {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// formatCanary : summary of a canary for display in a table
+func formatCanary(c engine.CanaryResult) string {
+	latency := c.Latency.Round(time.Millisecond)
+	if c.Err != nil {
+		return fmt.Sprintf("%s (%v): %v", c.Status, latency, c.Err)
+	}
+	return fmt.Sprintf("%s (%v): %d movies, %d/%d resolved", c.Status, latency, c.Movies, c.Resolved, c.Checked)
+}
+
+var (
+	// Output health checks as JSON
+	checkJSON bool
+	// Directory of the engine fixtures
+	fixturesDir string
+	// Query to record
+	recordQuery string
+)
+
 func init() {
+	recordEngineCmd.Flags().StringVar(&fixturesDir, "dir", filepath.Join("engine", "testdata", "fixtures"), "Directory to record the fixtures of engines to")
+	recordEngineCmd.Flags().StringVarP(&recordQuery, "query", "q", "", "Query to search for, defaults to the query recorded previously")
+	checkEngineCmd.Flags().BoolVar(&checkJSON, "json", false, "Output the health of engines as JSON")
+	engineCmd.AddCommand(checkEngineCmd)
+	engineCmd.AddCommand(recordEngineCmd)
 	engineCmd.AddCommand(showEngineCmd)
 	engineCmd.AddCommand(listEngineCmd)
 	rootCmd.AddCommand(engineCmd)
@@ -16,7 +16,8 @@ limitations under the License.
 package cmd
 
 import (
-	"github.com/go-phie/gophie/downloader"
+	"context"
+
 	"github.com/go-phie/gophie/engine"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -37,52 +38,45 @@ func listPager(pageNum int) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if !interactive() || viper.GetBool("json") {
+		runScripted(func(ctx context.Context) (engine.SearchResult, error) {
+			return selectedEngine.List(ctx, pageNum)
+		}, func(movie *engine.Movie) { handleMovie(movie, nil) })
+		return
+	}
 	selectedMovie := processList(pageNum, selectedEngine, compResult)
 	log.Debugf("Movie: %v\n", selectedMovie)
 	// Start Movie Download
-	if len(selectedMovie.SDownloadLink) < 1 {
-		if err = downloader.DownloadMovie(&selectedMovie, viper.GetString("output-dir")); err != nil {
-			log.Fatal(err)
-		}
-	} else {
-		var movieArray []engine.Movie
-		index := 0
-		for key, val := range selectedMovie.SDownloadLink {
-			movieArray = append(movieArray,
-				engine.Movie{
-					Index:          index,
-					Title:          key,
-					IsSeries:       false,
-					Source:         selectedMovie.Source,
-					DownloadLink:   val,
-					CoverPhotoLink: selectedMovie.CoverPhotoLink,
-					Description:    selectedMovie.Description,
-				})
-			index++
-		}
-		searchResult := engine.SearchResult{
-			Query:  selectedMovie.Title + " EPISODES",
-			Movies: movieArray,
-		}
-		selectedMovie = processList(pageNum, selectedEngine, searchResult)
-		if err = downloader.DownloadMovie(&selectedMovie, viper.GetString("output-dir")); err != nil {
-			log.Fatal(err)
-		}
-	}
+	handleMovie(&selectedMovie, func(result engine.SearchResult) engine.Movie {
+		return processList(pageNum, selectedEngine, result)
+	})
 }
 
 // listCmd represents the list command
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "lists the recent movies by page number",
-	Long:  ``,
+	Long: `List
+	gophie list -p 2
+
+	List the movies on the second page of the engine to select one to download
+
+	gophie list --json
+	gophie list --first --no-download
+
+	Print the movies as JSON, or the download link of the latest movie without prompting
+	`,
 	Run: func(cmd *cobra.Command, args []string) {
+		bindDownloadFlags(cmd)
+		bindScriptFlags(cmd)
 		listPager(pageNum)
 	},
 }
 
 func init() {
 	listCmd.Flags().IntVarP(&pageNum, "page", "p", 1, "Page Number to search and return from")
+	addDownloadFlags(listCmd)
+	addScriptFlags(listCmd)
 	rootCmd.AddCommand(listCmd)
 }
 
@@ -96,7 +90,7 @@ func processList(pageNum int, e engine.Engine, retrievedResult engine.SearchResu
 		items       []string
 	)
 	if reflect.DeepEqual(retrievedResult, compResult) {
-		result = ProcessFetchTask(func() engine.SearchResult { return e.List(pageNum) })
+		result = ProcessFetchTask(func(ctx context.Context) (engine.SearchResult, error) { return e.List(ctx, pageNum) })
 		items = append(result.Titles(), []string{">>> Next Page"}...)
 		if pageNum != 1 {
 			items = append([]string{"<<< Previous Page"}, items...)
@@ -16,14 +16,15 @@ limitations under the License.
 package cmd
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
 	"github.com/go-phie/gophie/downloader"
-	"github.com/manifoldco/promptui"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"os"
-	"path"
 )
 
 // ResumeCmd represents the resume command
@@ -31,46 +32,67 @@ var ResumeCmd = &cobra.Command{
 	Use:   "resume",
 	Short: "resume downloads for previously stopped movies",
 	Long: `Resume
-			Gophie makes use of the annie downloader to resume previous downloads
-	
-	It stores the list of the downloads and gives you the option to remove or resume
-	download
+			Gophie keeps a queue of downloads in the config dir (~/.gophie/downloads.json)
+
+	Select a paused, failed or interrupted download, or all of them, to queue them again.
+	Completed downloads are hidden unless their file was removed.
+	Queued downloads are then downloaded with up to --parallel-downloads at a time,
+	continuing from the partial files left by previous attempts
 	`,
 	Args: cobra.MinimumNArgs(0),
 	Run: func(cmd *cobra.Command, args []string) {
-		var resume []downloader.Downloader
-		var titles []string
-		file, err := os.Open(path.Join(viper.GetString("gophie_cache"), "downloadList.json"))
-		if err != nil {
-			log.Debug(err)
-			return
-		}
-		err = json.NewDecoder(file).Decode(&resume)
+		manager := downloader.NewManager(downloader.DefaultStore(), viper.GetInt("parallel-downloads"))
+		jobs, err := manager.Store().List()
 		if err != nil {
 			log.Fatal(err)
-			return
 		}
-		file.Close()
-		for _, i := range resume {
-			titles = append(titles, i.Name)
+		var (
+			unfinished []downloader.Job
+			titles     = []string{"All"}
+		)
+		// Finished downloads are hidden unless their file is gone
+		for _, job := range jobs {
+			if !job.Completed() {
+				unfinished = append(unfinished, job)
+				titles = append(titles, fmt.Sprintf("%s [%s]", job.Title, jobStatus(job)))
+			}
+		}
+		if len(unfinished) == 0 {
+			log.Info("No downloads to resume")
+			return
 		}
 
-		prompt := promptui.Select{
-			Label: "Resume List",
-			Items: titles,
+		choiceIndex, _ := SelectOpts("Resume List", titles)
+		selected := unfinished
+		if choiceIndex > 0 {
+			selected = unfinished[choiceIndex-1 : choiceIndex]
 		}
-		choiceIndex, _, err := prompt.Run()
+		for _, job := range selected {
+			if err = manager.Resume(job.ID); err != nil {
+				log.Errorf("Could not resume %s: %v", job.Title, err)
+			}
+		}
+		// Interrupted downloads are queued again
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		stopProgress := downloader.ShowProgress(manager)
+		manager.Run(ctx)
+		stopProgress()
+
+		jobs, err = manager.Store().List()
 		if err != nil {
-			log.Fatalf("Prompt failed: %v\n", err)
+			log.Fatal(err)
+		}
+		for _, job := range jobs {
+			for _, s := range selected {
+				if s.ID == job.ID {
+					fmt.Printf("%s: %s\n", job.Title, job.State)
+				}
+			}
 		}
-		selectedDownloader := resume[choiceIndex]
-		selectedDownloader.DownloadFile()
 	},
 }
 
 func init() {
-
-	//  ResumeCmd.PersistentFlags().StringVarP(
-	//    &outputPath, "output-dir", "o", "", "Path to download files to")
 	rootCmd.AddCommand(ResumeCmd)
 }
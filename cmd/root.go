@@ -21,7 +21,9 @@ import (
 	"path"
 	"runtime"
 	"strings"
+	"time"
 
+	"github.com/go-phie/gophie/engine"
 	homedir "github.com/mitchellh/go-homedir"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -44,10 +46,20 @@ var (
 	seleniumURL string
 	// CacheDir: The Directory to store all colly files
 	cacheDir string
+	// ConfigDir: The Directory to load engines and other configs from
+	configDir string
 	// Should Cache requests or not
 	ignoreCache bool
 	// use Chrome Driver
 	useChromeDriver bool
+	// Maximum duration of a search or list on an engine
+	timeout time.Duration
+	// Maximum duration of each engine when searching all engines
+	engineTimeout time.Duration
+	// Number of movies downloaded at the same time from the download queue
+	parallelDownloads int
+	// Download movies again without asking if they are already downloaded
+	force bool
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -64,6 +76,10 @@ var rootCmd = &cobra.Command{
 		}
 	},
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		// Scripts read only what they asked for
+		if scripted(cmd) {
+			return
+		}
 		fmt.Println("\n\nGophie - Bisoncorp (2020) (https://github.com/go-phie/gophie)")
 	},
 }
@@ -87,12 +103,18 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVarP(
 		&cacheDir, "cache-dir", "c", "", "The directory to store/lookup cache")
+	rootCmd.PersistentFlags().StringVar(
+		&configDir, "config-dir", "", "The directory to load engines and other configs from")
 	rootCmd.PersistentFlags().StringVarP(
 		&seleniumURL, "selenium-url", "s", "", "The URL of selenium instance to use")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Display Verbose logs")
 	rootCmd.PersistentFlags().StringVarP(&outputPath, "output-dir", "o", "", "Path to download files to")
 	rootCmd.PersistentFlags().BoolVar(&ignoreCache, "ignore-cache", false, "Ignore Cache and makes new requests")
 	rootCmd.PersistentFlags().BoolVar(&useChromeDriver, "use-chrome-driver", false, "Use Selenium Driver")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Maximum time to wait for an engine to respond (0 for no limit)")
+	rootCmd.PersistentFlags().BoolVar(&force, "force", false, "Download movies again without asking if they are already downloaded")
+	rootCmd.PersistentFlags().IntVar(&parallelDownloads, "parallel-downloads", 2, "Number of queued downloads to run at the same time")
+	rootCmd.PersistentFlags().DurationVar(&engineTimeout, "engine-timeout", 30*time.Second, "Maximum time to wait for each engine when searching all engines")
 
 	viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
@@ -100,8 +122,13 @@ func init() {
 	viper.BindPFlag("selenium-url", rootCmd.PersistentFlags().Lookup("selenium-url"))
 	viper.BindPFlag("output-dir", rootCmd.PersistentFlags().Lookup("output-dir"))
 	viper.BindPFlag("cache-dir", rootCmd.PersistentFlags().Lookup("cache-dir"))
+	viper.BindPFlag("config-dir", rootCmd.PersistentFlags().Lookup("config-dir"))
 	viper.BindPFlag("ignore-cache", rootCmd.PersistentFlags().Lookup("ignore-cache"))
 	viper.BindPFlag("use-chrome-driver", rootCmd.PersistentFlags().Lookup("use-chrome-driver"))
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("engine-timeout", rootCmd.PersistentFlags().Lookup("engine-timeout"))
+	viper.BindPFlag("parallel-downloads", rootCmd.PersistentFlags().Lookup("parallel-downloads"))
+	viper.BindPFlag("force", rootCmd.PersistentFlags().Lookup("force"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -128,4 +155,19 @@ func initConfig() {
 	viper.SetEnvKeyReplacer(replacer)
 	viper.SetEnvPrefix("gophie") // will be uppercased automatically
 	viper.AutomaticEnv()         // read in environment variables that match
+
+	viper.SetDefault("config-dir", path.Join(home, ".gophie"))
+	// Configs From config.yaml (or .json, .toml) in the config dir, e.g the urls of engines
+	viper.SetConfigName("config")
+	viper.AddConfigPath(viper.GetString("config-dir"))
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			log.Error(err)
+		}
+	}
+
+	// Engines described in the config dir are available alongside the built-in engines
+	if err := engine.LoadDeclarativeEngines(path.Join(viper.GetString("config-dir"), "engines")); err != nil {
+		log.Error(err)
+	}
 }
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-phie/gophie/engine"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// exitNoResults : exit code when nothing was found or nothing matched --select
+// Commands exit with 1 when an engine, the selection or a download fails
+const exitNoResults = 2
+
+// exitAlreadyDownloaded : exit code when the movie selected with --select or --first is already
+// downloaded and --force is not set
+const exitAlreadyDownloaded = 3
+
+// Flags that let search, list and stream run without prompting
+var scriptFlags = []string{"json", "select", "first", "no-download"}
+
+// addScriptFlags : add the flags that run cmd from scripts to cmd
+func addScriptFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("json", false, "Print the results as JSON, or the selected movie with --select or --first, instead of downloading")
+	cmd.Flags().String("select", "", "Select the movie at this position in the results from 0, or with this title, without prompting")
+	cmd.Flags().Bool("first", false, "Select the first movie of the results without prompting")
+	cmd.Flags().Bool("no-download", false, "Print the download links of the selected movie or episodes instead of downloading")
+}
+
+// bindScriptFlags : bind the script flags of the running command, as they are shared by several commands
+func bindScriptFlags(cmd *cobra.Command) {
+	for _, name := range scriptFlags {
+		viper.BindPFlag(name, cmd.Flags().Lookup(name))
+	}
+}
+
+// scripted : reports whether cmd was run with any flag meant for scripts, whose output must only
+// be what was asked for
+func scripted(cmd *cobra.Command) bool {
+	for _, name := range scriptFlags {
+		if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+			return true
+		}
+	}
+	return false
+}
+
+// interactive : reports whether movies are selected with prompts rather than with --select or --first
+func interactive() bool {
+	return viper.GetString("select") == "" && !viper.GetBool("first")
+}
+
+// printOnly : reports whether the selected movie is printed with --json or --no-download instead
+// of being downloaded or streamed
+func printOnly() bool {
+	return viper.GetBool("json") || viper.GetBool("no-download")
+}
+
+// runScripted : fetch movies with fetch and print them as JSON with --json alone, or handle the
+// movie chosen with --select or --first
+func runScripted(fetch fetchFunc, handle func(movie *engine.Movie)) {
+	result := ProcessFetchTask(fetch)
+	if interactive() {
+		printJSON(result)
+		return
+	}
+	movie, err := pickMovie(result, viper.GetString("select"))
+	if err != nil {
+		log.Error(err)
+		os.Exit(exitNoResults)
+	}
+	handle(&movie)
+}
+
+// pickMovie : the movie of result at the position choice from 0, or titled choice
+// Titles are matched regardless of case, and a movie whose title contains choice is picked when
+// none has it as title. The first movie is picked when choice is empty
+func pickMovie(result engine.SearchResult, choice string) (engine.Movie, error) {
+	choice = strings.TrimSpace(choice)
+	if len(result.Movies) == 0 {
+		return engine.Movie{}, fmt.Errorf("no movies to select from")
+	}
+	if choice == "" {
+		return result.Movies[0], nil
+	}
+	if index, err := strconv.Atoi(choice); err == nil {
+		if index < 0 || index >= len(result.Movies) {
+			return engine.Movie{}, fmt.Errorf("--select %d is not between 0 and %d", index, len(result.Movies)-1)
+		}
+		return result.Movies[index], nil
+	}
+	for _, movie := range result.Movies {
+		if strings.EqualFold(movie.Title, choice) {
+			return movie, nil
+		}
+	}
+	for _, movie := range result.Movies {
+		if strings.Contains(strings.ToLower(movie.Title), strings.ToLower(choice)) {
+			return movie, nil
+		}
+	}
+	return engine.Movie{}, fmt.Errorf("no movie matches %q", choice)
+}
+
+// handleMovie : download the selected movie, or the episodes of the selected series, or print
+// them with --json or --no-download. selectEpisode picks an episode of a series when neither
+// --all-episodes nor --episodes is set
+func handleMovie(movie *engine.Movie, selectEpisode func(result engine.SearchResult) engine.Movie) {
+	episodes := movie.Episodes()
+	if !hasEpisodes(movie, episodes) {
+		if printOnly() {
+			if err := applyVariant(movie, interactive()); err != nil {
+				log.Fatal(err)
+			}
+			printMovie(movie, nil)
+			return
+		}
+		downloadMovie(movie)
+		return
+	}
+	switch {
+	case allEpisodes || episodeRange != "":
+		episodes = seriesEpisodes(movie, episodes)
+	case interactive():
+		selected := selectEpisode(episodesResult(movie, episodes))
+		episodes = episodes[selected.Index : selected.Index+1]
+	case !printOnly():
+		log.Fatalf("%s is a series, choose its episodes with --all-episodes or --episodes", movie.Title)
+	}
+	if printOnly() {
+		episodes = episodeVariants(movie, episodes, interactive() && len(episodes) == 1)
+		if len(episodes) == 0 {
+			log.Fatal("No episode to print")
+		}
+		printMovie(movie, episodes)
+		return
+	}
+	downloadEpisodes(movie, episodes)
+}
+
+// printMovie : print movie as JSON with --json, with only the seasons of episodes if it is a series,
+// or else print the download link of the movie or of every episode
+func printMovie(movie *engine.Movie, episodes []engine.Episode) {
+	if viper.GetBool("json") {
+		if episodes != nil {
+			movie.Seasons = engine.GroupSeasons(episodes)
+		}
+		printJSON(movie)
+		return
+	}
+	if episodes == nil {
+		if movie.DownloadLink == nil {
+			log.Fatalf("%s has no download link", movie.Title)
+		}
+		fmt.Println(movie.DownloadLink)
+		return
+	}
+	for _, episode := range episodes {
+		if episode.Link == nil {
+			log.Warnf("%s %s has no download link", movie.Title, episode.Code())
+			continue
+		}
+		fmt.Println(episode.Link)
+	}
+}
+
+// printJSON : print v as indented JSON
+func printJSON(v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(b))
+}
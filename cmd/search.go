@@ -16,11 +16,12 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
 
-	"github.com/go-phie/gophie/downloader"
 	"github.com/go-phie/gophie/engine"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -35,14 +36,40 @@ var searchCmd = &cobra.Command{
 			gophie search The Longests Nights
 	
 	Search returns a list of movies which can be selected using arrowkeys on the keyboard
+
+	gophie search --all The Longest Nights
+
+	Search all engines at once and merge their results
+
+	gophie search --all-episodes Flower of Evil
+	gophie search --episodes 3-8 Flower of Evil
+
+	Download every episode, or a range of episodes, of the selected series
+
+	gophie search --subtitles Flower of Evil
+
+	Download the subtitles of the movie or episodes next to them when the engine has them
+
+	gophie search --quality 720p --max-size 1GB Jumanji
+
+	Pick the file of the movie in 720p if it has several, and at most 1GB
+
+	gophie search --json Jumanji
+	gophie search --select 2 --no-download Jumanji
+	gophie search --first --json Jumanji
+
+	Print the results as JSON, or select a movie without prompting and print its download link
+	or details instead of downloading it. Exits with 2 when nothing is found
 	`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		bindDownloadFlags(cmd)
+		bindScriptFlags(cmd)
 		// Engine is set from root.go
 		page := strconv.Itoa(pageNum)
 		query := strings.Join(args, " ")
 		// only run pagination for
-		if strings.ToLower(viper.GetString("engine")) == "tvseries" {
+		if !searchAll && strings.ToLower(viper.GetString("engine")) == "tvseries" {
 			searchPager(query, page)
 		} else {
 			searchPager(query)
@@ -50,47 +77,59 @@ var searchCmd = &cobra.Command{
 	},
 }
 
+// Search all engines instead of the selected engine
+var searchAll bool
+
 func init() {
 	searchCmd.Flags().IntVarP(&pageNum, "page", "p", 1, "Page Number to search and return from")
+	searchCmd.Flags().BoolVar(&searchAll, "all", false, "Search all engines at once and merge their results")
+	addDownloadFlags(searchCmd)
+	addScriptFlags(searchCmd)
 	rootCmd.AddCommand(searchCmd)
 }
 
 func searchPager(params ...string) {
-	selectedEngine, err := engine.GetEngine(viper.GetString("engine"))
+	if !interactive() || viper.GetBool("json") {
+		runScripted(searchTask(params...), func(movie *engine.Movie) { handleMovie(movie, nil) })
+		return
+	}
+	var (
+		selectedEngine engine.Engine
+		selectedMovie  engine.Movie
+		err            error
+	)
+	if searchAll {
+		selectedMovie = processSearchAll(params[0])
+		// Continue with the engine the movie came from
+		selectedEngine, err = engine.GetEngine(selectedMovie.Source)
+	} else {
+		selectedEngine, err = engine.GetEngine(viper.GetString("engine"))
+		if err == nil {
+			selectedMovie = processSearch(selectedEngine, compResult, params...)
+		}
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	selectedMovie := processSearch(selectedEngine, compResult, params...)
 	// Start Movie Download
-	if len(selectedMovie.SDownloadLink) < 1 {
-		if err = downloader.DownloadMovie(&selectedMovie, viper.GetString("output-dir")); err != nil {
-			log.Fatal(err)
-		}
-	} else {
-		var movieArray []engine.Movie
-		index := 0
-		for key, val := range selectedMovie.SDownloadLink {
-			movieArray = append(movieArray,
-				engine.Movie{
-					Index:          index,
-					Title:          key,
-					IsSeries:       false,
-					Source:         selectedMovie.Source,
-					DownloadLink:   val,
-					CoverPhotoLink: selectedMovie.CoverPhotoLink,
-					Description:    selectedMovie.Description,
-				})
-			index++
-		}
-		searchResult := engine.SearchResult{
-			Query:  selectedMovie.Title + " EPISODES",
-			Movies: movieArray,
-		}
-		selectedMovie = processSearch(selectedEngine, searchResult, params...)
-		if err = downloader.DownloadMovie(&selectedMovie, viper.GetString("output-dir")); err != nil {
-			log.Fatal(err)
+	handleMovie(&selectedMovie, func(result engine.SearchResult) engine.Movie {
+		return processSearch(selectedEngine, result, params...)
+	})
+}
+
+// searchTask : the search of the selected engine, or of all engines with --all
+func searchTask(params ...string) fetchFunc {
+	if searchAll {
+		return func(ctx context.Context) (engine.SearchResult, error) {
+			federated, err := searchAllEngines(ctx, params[0])
+			return federated.SearchResult, err
 		}
 	}
+	e, err := engine.GetEngine(viper.GetString("engine"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return func(ctx context.Context) (engine.SearchResult, error) { return e.Search(ctx, params...) }
 }
 
 func processSearch(e engine.Engine, retrievedResult engine.SearchResult, params ...string) engine.Movie {
@@ -104,7 +143,7 @@ func processSearch(e engine.Engine, retrievedResult engine.SearchResult, params
 	query := params[0]
 	if len(params) > 1 {
 		pageNum, _ = strconv.Atoi(params[1])
-		result = ProcessFetchTask(func() engine.SearchResult { return e.Search(params...) })
+		result = ProcessFetchTask(func(ctx context.Context) (engine.SearchResult, error) { return e.Search(ctx, params...) })
 		items = append(result.Titles(), []string{">>> Next Page"}...)
 		log.Debug(result)
 		if pageNum != 1 {
@@ -121,7 +160,7 @@ func processSearch(e engine.Engine, retrievedResult engine.SearchResult, params
 		}
 	} else {
 		if reflect.DeepEqual(retrievedResult, compResult) {
-			result = ProcessFetchTask(func() engine.SearchResult { return e.Search(query) })
+			result = ProcessFetchTask(func(ctx context.Context) (engine.SearchResult, error) { return e.Search(ctx, query) })
 			_, choice = SelectOpts(result.Query, result.Titles())
 		} else {
 			result = retrievedResult
@@ -138,3 +177,49 @@ func processSearch(e engine.Engine, retrievedResult engine.SearchResult, params
 	}
 	return selectedMovie
 }
+
+// Search all engines and select amongst the merged results
+func processSearchAll(query string) engine.Movie {
+	var federated engine.FederatedResult
+	ProcessFetchTask(func(ctx context.Context) (engine.SearchResult, error) {
+		var err error
+		federated, err = searchAllEngines(ctx, query)
+		return federated.SearchResult, err
+	})
+
+	// Equivalent movies from several engines are shown once
+	var items []string
+	for _, group := range federated.Groups {
+		title := group.Title
+		if group.Year != 0 {
+			title = fmt.Sprintf("%s (%d)", title, group.Year)
+		}
+		items = append(items, fmt.Sprintf("%s [%s]", title, strings.Join(group.Sources(), ", ")))
+	}
+	choiceIndex, _ := SelectOpts(federated.Query, items)
+	group := federated.Groups[choiceIndex]
+	if len(group.Movies) == 1 {
+		return group.Movies[0]
+	}
+
+	// Select the engine to download from
+	items = []string{}
+	for _, movie := range group.Movies {
+		items = append(items, fmt.Sprintf("%s - %s %s", movie.Source, movie.Title, movie.Size))
+	}
+	choiceIndex, _ = SelectOpts("Select a source", items)
+	return group.Movies[choiceIndex]
+}
+
+// searchAllEngines : search every engine for query, warning about the engines that failed
+func searchAllEngines(ctx context.Context, query string) (engine.FederatedResult, error) {
+	engines := engine.GetEngines()
+	federated, err := engine.SearchAll(ctx, engines, viper.GetDuration("engine-timeout"), query)
+	for name, failure := range federated.Failed {
+		log.Debugf("%s failed: %v", name, failure)
+	}
+	if len(federated.Failed) > 0 {
+		log.Warnf("%d of %d engines failed, use --verbose for details", len(federated.Failed), len(engines))
+	}
+	return federated, err
+}
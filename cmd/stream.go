@@ -16,16 +16,27 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/bisoncorps/mplayer"
+	"github.com/go-phie/gophie/downloader"
 	"github.com/go-phie/gophie/engine"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var selectedPlayer string
+var (
+	selectedPlayer         string
+	downloadWhileStreaming bool
+)
 
 const DEFAULT_PLAYER = "browser"
 
@@ -39,31 +50,149 @@ Gophie stream allows streaming videos using browser player. You can pass a query
 example:
   gophie stream Jumanji --player vlc (stream Jumanji using VLC Media Player)
   gophie stream -e fzmovies (check for latest movies on fzmovies for streaming)
+  gophie stream Jumanji --first --no-download (print the link to stream the first Jumanji found)
+  gophie stream Jumanji --download (download Jumanji and play it as it is downloaded)
+  gophie stream Jumanji --download --subtitles (download its subtitles next to it too)
+
+Movies in the download queue are played from disk as they are downloaded, downloading first the parts the player seeks to.
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
+		bindScriptFlags(cmd)
+		viper.BindPFlag("subtitles", cmd.Flags().Lookup("subtitles"))
 		selectedEngine, err := engine.GetEngine(viper.GetString("engine"))
 		if err != nil {
 			log.Fatal(err)
 		}
 		query := strings.Join(args, " ")
+		if !interactive() || viper.GetBool("json") {
+			runScripted(func(ctx context.Context) (engine.SearchResult, error) {
+				if query == "" {
+					return selectedEngine.List(ctx, 1)
+				}
+				return selectedEngine.Search(ctx, query, "1")
+			}, streamMovie)
+			return
+		}
 		var movie engine.Movie
 		if query == "" {
 			movie = processList(1, selectedEngine, compResult)
 		} else {
 			movie = processSearch(selectedEngine, compResult, query, "1")
 		}
-		p, err := mplayer.GetPlayer(selectedPlayer)
-		if err != nil {
+		streamMovie(&movie)
+	},
+}
+
+// streamMovie : play movie with the selected player, or print it with --json or --no-download
+// A movie in the download queue, or downloaded with --download, is played from disk as it is downloaded
+func streamMovie(movie *engine.Movie) {
+	if printOnly() {
+		if err := applyVariant(movie, interactive()); err != nil {
 			log.Fatal(err)
 		}
-		p.SetURL(movie.DownloadLink.String())
-		p.SetTitle(movie.Title)
+		printMovie(movie, nil)
+		return
+	}
+	if movie.DownloadLink == nil {
+		log.Fatalf("%s has no link to stream", movie.Title)
+	}
+	p, err := mplayer.GetPlayer(selectedPlayer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.SetTitle(movie.Title)
+	if downloadWhileStreaming {
+		if err := applyVariant(movie, interactive()); err != nil {
+			log.Fatal(err)
+		}
+	}
+	job, err := downloader.DefaultStore().Find(downloader.NewJob(movie, viper.GetString("output-dir")))
+	if err == nil || downloadWhileStreaming {
+		streamDownload(p, movie, job, err == nil)
+		return
+	}
+	p.SetURL(movie.DownloadLink.String())
+	p.Play()
+}
+
+// streamDownload : play the file of movie from a local server as it is downloaded, downloading it
+// in this process unless another process already is. previous is the download of movie in the
+// queue if queued is set
+func streamDownload(p mplayer.Player, movie *engine.Movie, previous downloader.Job, queued bool) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	manager := downloader.NewManager(downloader.DefaultStore(), 1)
+
+	job := previous
+	if !queued || job.State != downloader.StateRunning {
+		job = downloader.NewJob(movie, viper.GetString("output-dir"))
+		job.Subtitles = viper.GetBool("subtitles")
+		var err error
+		job, err = manager.Queue(ctx, job, false)
+		if errors.Is(err, downloader.ErrAlreadyDownloaded) {
+			log.Infof("Playing %s from %s", movie.Title, job.File)
+		} else if err != nil {
+			log.Fatal(err)
+		}
+	}
+	// The seeks of the player are only downloaded first when this process downloads the movie
+	downloaded := make(chan struct{})
+	if job.State == downloader.StateQueued {
+		go func() {
+			defer close(downloaded)
+			if _, err := manager.DownloadQueued(ctx, job.ID); err != nil && ctx.Err() == nil {
+				log.Error(err)
+			}
+		}()
+	} else {
+		close(downloaded)
+	}
+	job, err := manager.WaitStarted(ctx, job.ID)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Fatal(err)
+		}
+		<-downloaded
+		return
+	}
+	if job.State == downloader.StateFailed {
+		log.Fatalf("Download of %s failed: %s", movie.Title, job.Error)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := &http.Server{Handler: StreamHandler(downloader.NewProxy(manager))}
+	go server.Serve(listener)
+	defer server.Close()
+	p.SetURL(fmt.Sprintf("http://%s/stream/%s", listener.Addr(), job.ID))
+	played := make(chan struct{})
+	go func() {
+		defer close(played)
 		p.Play()
-	},
+	}()
+	select {
+	case <-played:
+	case <-ctx.Done():
+	}
+
+	select {
+	case <-downloaded:
+	default:
+		log.Infof("%s is still downloading, interrupt to stop and continue it later with `gophie resume`", movie.Title)
+		stopProgress := downloader.ShowProgress(manager)
+		<-downloaded
+		stopProgress()
+	}
 }
 
 func init() {
 	streamCmd.Flags().StringVarP(
 		&selectedPlayer, "player", "p", DEFAULT_PLAYER, "Player to use for streaming")
+	streamCmd.Flags().BoolVar(
+		&downloadWhileStreaming, "download", false, "Download the movie while playing it from disk")
+	streamCmd.Flags().Bool("subtitles", false, "Download subtitles next to the movie with --download when available")
+	addScriptFlags(streamCmd)
 	rootCmd.AddCommand(streamCmd)
 }
@@ -1,40 +1,284 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/briandowns/spinner"
+	"github.com/go-phie/gophie/downloader"
 	"github.com/go-phie/gophie/engine"
 	"github.com/manifoldco/promptui"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+var (
+	// Download every episode of the selected series
+	allEpisodes bool
+	// Episodes of the selected series to download e.g 3-8
+	episodeRange string
+)
+
+// addDownloadFlags : add the flags that choose what is downloaded to cmd
+func addDownloadFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&allEpisodes, "all-episodes", false, "Download every episode of the selected series")
+	cmd.Flags().StringVar(&episodeRange, "episodes", "", "Episodes of the selected series to download e.g 3-8 or 1,4,6-")
+	cmd.Flags().Bool("subtitles", false, "Download subtitles next to the movie or episodes when available")
+	cmd.Flags().String("quality", "", "Preferred quality of movies available in several e.g 720p")
+	cmd.Flags().String("max-size", "", "Largest file to download e.g 700MB or 1.5GB")
+}
+
+// bindDownloadFlags : bind the download flags of the running command, as they are shared by several commands
+func bindDownloadFlags(cmd *cobra.Command) {
+	for _, name := range []string{"subtitles", "quality", "max-size"} {
+		viper.BindPFlag(name, cmd.Flags().Lookup(name))
+	}
+}
+
 // fetchFunc : A function that performs initiates the fetching process of the
 // scrapers. It could be the `Search` or `List` function of the engine
-type fetchFunc func() engine.SearchResult
+type fetchFunc func(ctx context.Context) (engine.SearchResult, error)
+
+// withTimeout : derive a context bounded by the configured timeout if any
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := viper.GetDuration("timeout"); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
 
 // ProcessFetchTask : Process a task in the Terminal and show processing
+// The task is cancelled on interrupt or once the configured timeout elapses
 func ProcessFetchTask(fn fetchFunc) engine.SearchResult {
-	var result engine.SearchResult
-	if !viper.GetBool("verbose") {
-		s := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
-		s.Suffix = " Fetching Data..."
-		s.Writer = os.Stderr
-		s.Start()
-		result = fn()
-		s.Stop()
-	} else {
-		result = fn()
+	var (
+		result engine.SearchResult
+		err    error
+	)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	stopSpinner := startSpinner("Fetching Data...")
+	result, err = fn(ctx)
+	stopSpinner()
+	if err != nil && !errors.Is(err, engine.ErrNoResults) {
+		log.Fatal(err)
 	}
 	if len(result.Movies) <= 0 {
 		log.Info("No Results Found")
-		os.Exit(0)
+		os.Exit(exitNoResults)
+	}
+	return result
+}
+
+// startSpinner : show a spinner on stderr until the returned function is called
+// The spinner is not shown in verbose mode as it would be mixed with the logs
+func startSpinner(suffix string) func() {
+	if viper.GetBool("verbose") {
+		return func() {}
+	}
+	s := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
+	s.Suffix = " " + suffix
+	s.Writer = os.Stderr
+	s.Start()
+	return s.Stop
+}
+
+// downloadMovie : download a movie to the output dir, asking before downloading it again
+// unless --force is set. Movies selected without prompts are not downloaded again without --force
+func downloadMovie(movie *engine.Movie) {
+	if err := applyVariant(movie, interactive()); err != nil {
+		log.Fatal(err)
+	}
+	outputDir := viper.GetString("output-dir")
+	err := downloader.DownloadMovie(movie, outputDir, viper.GetBool("force"))
+	if errors.Is(err, downloader.ErrAlreadyDownloaded) && !interactive() {
+		// Scripts cannot answer the prompt, they download again with --force
+		log.Errorf("%v, use --force to download it again", err)
+		os.Exit(exitAlreadyDownloaded)
+	}
+	if errors.Is(err, downloader.ErrAlreadyDownloaded) {
+		prompt := promptui.Prompt{
+			Label:     fmt.Sprintf("%v. Download again", err),
+			IsConfirm: true,
+		}
+		if _, promptErr := prompt.Run(); promptErr != nil {
+			log.Info("Download skipped")
+			return
+		}
+		err = downloader.DownloadMovie(movie, outputDir, true)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// applyVariant : make the variant of movie chosen with chooseVariant its download link
+func applyVariant(movie *engine.Movie, pick bool) error {
+	variant, err := chooseVariant(movie.Title, movieVariants(movie), pick)
+	if err != nil {
+		return err
+	}
+	movie.DownloadLink = variant.Link
+	if variant.Size != "" {
+		movie.Size = variant.Size
+	}
+	return nil
+}
+
+// hasEpisodes : reports whether movie is a series to select episodes from
+// A single episode outside of a series, e.g the episodes listed by TvSeries, is downloaded as a movie
+func hasEpisodes(movie *engine.Movie, episodes []engine.Episode) bool {
+	return len(episodes) > 1 || (len(episodes) == 1 && movie.IsSeries)
+}
+
+// episodesResult : the episodes of series as movies to select from, in order
+// The Index of every movie is the index of its episode
+func episodesResult(series *engine.Movie, episodes []engine.Episode) engine.SearchResult {
+	result := engine.SearchResult{Query: series.Title + " EPISODES"}
+	for index, episode := range episodes {
+		result.Movies = append(result.Movies, engine.Movie{
+			Index:          index,
+			Title:          fmt.Sprintf("%s - %s", episode.Code(), episode.Title),
+			Size:           episode.Size,
+			Source:         series.Source,
+			DownloadLink:   episode.Link,
+			DetailLink:     series.DetailLink,
+			CoverPhotoLink: series.CoverPhotoLink,
+			Description:    series.Description,
+		})
 	}
 	return result
 }
 
+// seriesEpisodes : the episodes of series in --episodes, or every episode with --all-episodes
+func seriesEpisodes(series *engine.Movie, episodes []engine.Episode) []engine.Episode {
+	if episodeRange != "" {
+		var err error
+		if episodes, err = filterEpisodes(episodes, episodeRange); err != nil {
+			log.Fatal(err)
+		}
+		if len(episodes) == 0 {
+			log.Fatalf("%s has no episodes in %s", series.Title, episodeRange)
+		}
+	}
+	return episodes
+}
+
+// downloadEpisodes : download episodes of series to the output dir
+// Episodes that are already downloaded are skipped unless --force is set
+func downloadEpisodes(series *engine.Movie, episodes []engine.Episode) {
+	selected := episodeVariants(series, episodes, interactive() && len(episodes) == 1)
+	if len(selected) == 0 {
+		log.Fatal("No episode to download")
+	}
+	err := downloader.DownloadEpisodes(series, selected, viper.GetString("output-dir"), viper.GetBool("force"))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// episodeVariants : episodes of series with the link of the variant chosen with chooseVariant,
+// without the episodes that have no variant that fits --max-size
+func episodeVariants(series *engine.Movie, episodes []engine.Episode, pick bool) []engine.Episode {
+	var selected []engine.Episode
+	for _, episode := range episodes {
+		variants := episode.Variants
+		if len(variants) == 0 {
+			variants = []engine.Variant{{Size: episode.Size, Link: episode.Link}}
+		}
+		variant, err := chooseVariant(episode.Title, variants, pick)
+		if err != nil {
+			log.Warnf("Skipping %s %s: %v", series.Title, episode.Code(), err)
+			continue
+		}
+		episode.Link = variant.Link
+		if variant.Size != "" {
+			episode.Size = variant.Size
+		}
+		selected = append(selected, episode)
+	}
+	return selected
+}
+
+// filterEpisodes : the episodes whose number is in spec, a comma separated list of numbers
+// and ranges such as 3-8, or 6- for every episode from the sixth
+func filterEpisodes(episodes []engine.Episode, spec string) ([]engine.Episode, error) {
+	type numberRange struct{ from, to int }
+	var ranges []numberRange
+	for _, part := range strings.Split(spec, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil || from < 0 {
+			return nil, fmt.Errorf("invalid episodes %q, expected e.g 3-8 or 1,4,6-", spec)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if strings.TrimSpace(bounds[1]) == "" {
+				to = -1 // Open range
+			} else if to, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil || to < from {
+				return nil, fmt.Errorf("invalid episodes %q, expected e.g 3-8 or 1,4,6-", spec)
+			}
+		}
+		ranges = append(ranges, numberRange{from, to})
+	}
+	var selected []engine.Episode
+	for _, episode := range episodes {
+		for _, r := range ranges {
+			if episode.Number >= r.from && (r.to < 0 || episode.Number <= r.to) {
+				selected = append(selected, episode)
+				break
+			}
+		}
+	}
+	return selected, nil
+}
+
+// movieVariants : the files the movie is available as, the movie itself when the engine found no variants
+func movieVariants(movie *engine.Movie) []engine.Variant {
+	if len(movie.Variants) > 0 {
+		return movie.Variants
+	}
+	return []engine.Variant{{Quality: movie.Quality, Size: movie.Size, Link: movie.DownloadLink}}
+}
+
+// chooseVariant : the variant to download amongst variants of the movie or episode titled title
+// The variant is selected from --quality and --max-size when they are set, and otherwise picked
+// when pick is set. The first variant, which engines give as the download link, is the default
+func chooseVariant(title string, variants []engine.Variant, pick bool) (engine.Variant, error) {
+	quality := viper.GetString("quality")
+	var maxSize int64
+	if size := viper.GetString("max-size"); size != "" {
+		if maxSize = engine.ParseSize(size); maxSize <= 0 {
+			return engine.Variant{}, fmt.Errorf("invalid --max-size %q, expected e.g 700MB or 1.5GB", size)
+		}
+	}
+	if quality != "" || maxSize > 0 {
+		variant, err := engine.SelectVariant(variants, quality, maxSize)
+		if err != nil {
+			return variant, fmt.Errorf("%s has no file of at most %s", title, downloader.FormatBytes(maxSize))
+		}
+		return variant, nil
+	}
+	if pick && len(variants) > 1 {
+		var items []string
+		for _, variant := range variants {
+			items = append(items, variant.String())
+		}
+		index, _ := SelectOpts("Select a file of "+title, items)
+		return variants[index], nil
+	}
+	return variants[0], nil
+}
+
 // SelectOpts : use promptui to select amongst options
 func SelectOpts(title string, options []string) (int, string) {
 	prompt := promptui.Select{
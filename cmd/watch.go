@@ -0,0 +1,231 @@
+/*
+Copyright © 2020 Bisoncorps
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-phie/gophie/downloader"
+	"github.com/go-phie/gophie/engine"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	// Only report the new movies and episodes of a subscription
+	watchNotify bool
+	// Interval between checks of the watchlist, only checked once if zero
+	watchEvery time.Duration
+	// Command run for every new movie or episode
+	watchExec string
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch engines for new movies and episodes",
+	Long: `Watch searches of engines, or their latest movies, and download new movies and episodes
+
+		gophie watch add tvseries The Boys (Watch a search of an engine)
+		gophie watch list (All watched searches)
+		gophie watch remove [id...] (Stop watching searches)
+		gophie watch run (Download the new movies and episodes of every watched search)
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(`Watchlist
+
+	gophie watch add - Watch a search of an engine
+	gophie watch list - All watched searches
+	gophie watch remove - Stop watching searches
+	gophie watch run - Download the new movies and episodes of every watched search`)
+	},
+}
+
+// addWatchCmd represents the watch add command
+var addWatchCmd = &cobra.Command{
+	Use:   "add [engine] [query...]",
+	Short: "Watch a search of an engine, or its latest movies without a query",
+	Long: `Watch a search of an engine for new movies and episodes. What the engine already has
+is recorded on the first run, and only what comes out after is downloaded
+
+	gophie watch add netnaija Flower of Evil
+	gophie watch add tvseries The Boys --quality 720p
+	gophie watch add fzmovies --notify (Only report the latest movies of fzmovies)
+	`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := engine.GetEngine(args[0]); err != nil {
+			log.Fatal(err)
+		}
+		quality, _ := cmd.Flags().GetString("quality")
+		subtitles, _ := cmd.Flags().GetBool("subtitles")
+		sub, err := downloader.DefaultWatchlist().Add(downloader.Subscription{
+			Engine:    args[0],
+			Query:     strings.Join(args[1:], " "),
+			Quality:   quality,
+			Subtitles: subtitles,
+			Notify:    watchNotify,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Watching %s as %s", subscriptionName(sub), sub.ID)
+	},
+}
+
+// listWatchCmd represents the watch list command
+var listWatchCmd = &cobra.Command{
+	Use:   "list",
+	Short: "lists all watched searches",
+	Run: func(cmd *cobra.Command, args []string) {
+		subscriptions, err := downloader.DefaultWatchlist().List()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(subscriptions) == 0 {
+			log.Info("Nothing is watched")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tENGINE\tQUERY\tACTION\tCHECKED")
+		for _, sub := range subscriptions {
+			action := "download"
+			if sub.Notify {
+				action = "notify"
+			}
+			checked := "never"
+			if !sub.Checked.IsZero() {
+				checked = sub.Checked.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", sub.ID, sub.Engine, sub.Query, action, checked)
+		}
+		w.Flush()
+	},
+}
+
+// removeWatchCmd represents the watch remove command
+var removeWatchCmd = &cobra.Command{
+	Use:   "remove [id...]",
+	Short: "Stop watching searches",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		failed := false
+		for _, id := range args {
+			if err := downloader.DefaultWatchlist().Remove(id); err != nil {
+				log.Errorf("Could not remove %s: %v", id, err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// runWatchCmd represents the watch run command
+var runWatchCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Download the new movies and episodes of every watched search",
+	Long: `Check every watched search for new movies and episodes and download them, or only
+report them for searches watched with --notify. Run it from cron, or keep it running with --every
+
+	gophie watch run
+	gophie watch run --every 1h
+	gophie watch run --exec 'notify-send "New on $GOPHIE_ENGINE" "$GOPHIE_TITLE"'
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		watchlist := downloader.DefaultWatchlist()
+		manager := downloader.NewManager(downloader.DefaultStore(), viper.GetInt("parallel-downloads"))
+		for {
+			queued, err := watchlist.Check(ctx, manager, viper.GetString("output-dir"), notifyRelease)
+			if err != nil && ctx.Err() == nil {
+				log.Fatal(err)
+			}
+			if len(queued) > 0 {
+				log.Infof("Downloading %d new movies and episodes", len(queued))
+				// Interrupted downloads are queued again
+				stopProgress := downloader.ShowProgress(manager)
+				manager.Run(ctx)
+				stopProgress()
+			}
+			if watchEvery <= 0 || ctx.Err() != nil {
+				return
+			}
+			log.Debugf("Checking again at %s", time.Now().Add(watchEvery).Format("15:04"))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchEvery):
+			}
+		}
+	},
+}
+
+// subscriptionName : the search of sub e.g `Flower of Evil on netnaija`
+func subscriptionName(sub downloader.Subscription) string {
+	if sub.Query == "" {
+		return "the latest movies of " + sub.Engine
+	}
+	return fmt.Sprintf("%s on %s", sub.Query, sub.Engine)
+}
+
+// notifyRelease : report a new movie or episode, and run --exec with it in the environment
+// as GOPHIE_TITLE, GOPHIE_ENGINE, GOPHIE_LINK and GOPHIE_QUERY
+func notifyRelease(sub downloader.Subscription, release downloader.Release) {
+	fmt.Printf("New: %s (%s)\n", release.Title(), subscriptionName(sub))
+	if watchExec == "" {
+		return
+	}
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	command := exec.Command(shell, flag, watchExec)
+	command.Env = append(os.Environ(),
+		"GOPHIE_TITLE="+release.Title(),
+		"GOPHIE_ENGINE="+sub.Engine,
+		"GOPHIE_LINK="+release.Link(),
+		"GOPHIE_QUERY="+sub.Query,
+	)
+	command.Stdout, command.Stderr = os.Stdout, os.Stderr
+	if err := command.Run(); err != nil {
+		log.Errorf("Could not run %s for %s: %v", watchExec, release.Title(), err)
+	}
+}
+
+func init() {
+	addWatchCmd.Flags().BoolVar(&watchNotify, "notify", false, "Only report new movies and episodes without downloading them")
+	addWatchCmd.Flags().String("quality", "", "Preferred quality of movies available in several e.g 720p")
+	addWatchCmd.Flags().Bool("subtitles", false, "Download subtitles next to the movies and episodes when available")
+	runWatchCmd.Flags().DurationVar(&watchEvery, "every", 0, "Keep running and check again at this interval e.g 1h")
+	runWatchCmd.Flags().StringVar(&watchExec, "exec", "", "Command to run for every new movie or episode")
+	watchCmd.AddCommand(addWatchCmd)
+	watchCmd.AddCommand(listWatchCmd)
+	watchCmd.AddCommand(removeWatchCmd)
+	watchCmd.AddCommand(runWatchCmd)
+	rootCmd.AddCommand(watchCmd)
+}
@@ -1,182 +1,756 @@
 package downloader
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-phie/gophie/engine"
-	"github.com/iawia002/annie/downloader"
-	"github.com/iawia002/annie/extractors/types"
-	"github.com/iawia002/annie/request"
-	"github.com/iawia002/annie/utils"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
-// Extract is the main function for extracting data before passing to Annie
-func Extract(url, source string) ([]*types.Data, error) {
+// Defaults of the Downloader
+const (
+	DefaultSegments = 4 // Number of parallel range requests
+	DefaultRetries  = 5 // Number of retries of a segment that stopped without progress
+	// Files smaller than this are not split in segments
+	minSegmentSize = 4 << 20
+	maxBackoff     = 30 * time.Second
+	// How often the progress of segments is saved and reported
+	progressInterval = time.Second
+)
+
+// Delay before the first retry of a segment, doubled on every retry up to maxBackoff
+var retryBackoff = time.Second
+
+// Errors returned by the Downloader
+var (
+	// ErrLinkExpired : the link no longer serves the file, e.g because its token expired
+	ErrLinkExpired      = errors.New("download link expired")
+	ErrSizeMismatch     = errors.New("downloaded size does not match the expected size")
+	ErrChecksumMismatch = errors.New("checksum of the downloaded file does not match")
+)
+
+// Downloader : resumable downloader of a single file over HTTP
+// The file is downloaded to <file>.part with up to Segments parallel range requests, and the
+// progress of every segment is kept in <file>.part.json so that an interrupted download
+// continues where it stopped
+type Downloader struct {
+	URL       string // URL Source
+	Dir       string // Directory to store the file
+	Name      string // Name of file
+	Source    string // Name of the Source
+	Size      int64  // Size of the file
+	Completed bool   // Status of Download
+	FileName  string // Name of the file in Dir, found from the response if empty
+	BaseName  string // Name of the file without extension when FileName is found from the response
+	Segments  int    // Number of parallel range requests, DefaultSegments if 0
+	Retries   int    // Retries of a segment that stopped without progress, DefaultRetries if 0
+	// Expected checksum of the file as <algorithm>:<hex> with md5, sha1 or sha256
+	Checksum string
+	// Headers sent with every request for the file
+	Headers map[string]string
+	// Called with the bytes downloaded so far while downloading
+	OnProgress func(downloaded, size int64) `json:"-"`
+	// Called with the path and size of the file once they are known, before it is downloaded
+	OnStart func(file string, size int64) `json:"-"`
+	// Offsets of the file to download first, e.g where a player streaming the file seeks to
+	Priority <-chan int64 `json:"-"`
+	Client   *http.Client `json:"-"`
+
+	downloaded int64 // Accessed atomically
+}
+
+// segment : a byte range of the file and how much of it is downloaded
+type segment struct {
+	Start int64
+	End   int64 // Inclusive, -1 if the size of the file is unknown
+	Done  int64
+}
+
+// partState : progress of a partial download saved next to the partial file
+type partState struct {
+	URL      string
+	Size     int64
+	Segments []*segment
+}
+
+// Path : the path of the downloaded file
+func (f *Downloader) Path() string {
+	return filepath.Join(f.Dir, f.FileName)
+}
 
-	filename, ext, err := utils.GetNameAndExt(url)
+func (f *Downloader) client() *http.Client {
+	if f.Client != nil {
+		return f.Client
+	}
+	return http.DefaultClient
+}
+
+// newRequest : a GET or HEAD request for the file that is not transparently compressed
+func (f *Downloader) newRequest(ctx context.Context, method string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, f.URL, nil)
 	if err != nil {
 		return nil, err
 	}
-	size, err := request.Size(url, url)
+	setHeaders(req, f.Headers)
+	// Compressed responses cannot be resumed at a byte offset
+	req.Header.Set("Accept-Encoding", "identity")
+	return req, nil
+}
+
+// setHeaders : set every header of headers on req
+func setHeaders(req *http.Request, headers map[string]string) {
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// probe : find the size and name of the file and whether the server supports range requests
+func (f *Downloader) probe(ctx context.Context) (bool, error) {
+	req, err := f.newRequest(ctx, http.MethodGet)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	streams := map[string]*types.Stream{
-		"default": {
-			Parts: []*types.Part{
-				{
-					URL:  url,
-					Size: size,
-					Ext:  ext,
-				},
-			},
-			Size: size,
-		},
-	}
-	contentType, err := request.ContentType(url, url)
+	// A range request tells whether ranges are supported, without downloading the file
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := f.client().Do(req)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
+	defer resp.Body.Close()
 
-	return []*types.Data{
-		{
-			Site:    source,
-			Title:   filename,
-			Type:    types.DataType(contentType),
-			Streams: streams,
-			URL:     url,
-		},
-	}, nil
+	var ranges bool
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		ranges = true
+		// Content-Range: bytes 0-0/<size>
+		contentRange := resp.Header.Get("Content-Range")
+		if i := strings.LastIndex(contentRange, "/"); i >= 0 {
+			if size, err := strconv.ParseInt(contentRange[i+1:], 10, 64); err == nil {
+				f.Size = size
+			}
+		}
+	case http.StatusOK:
+		if resp.ContentLength > 0 {
+			f.Size = resp.ContentLength
+		}
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		return false, fmt.Errorf("%w: %s: %s", ErrLinkExpired, f.URL, resp.Status)
+	default:
+		return false, fmt.Errorf("%s: %s", f.URL, resp.Status)
+	}
+	// Expired links of file hosts usually lead to an html page instead of the file
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return false, fmt.Errorf("%w: %s is a web page", ErrLinkExpired, f.URL)
+	}
+	if f.FileName == "" {
+		if f.BaseName != "" {
+			f.FileName = f.BaseName + filepath.Ext(fileName(resp, ""))
+		} else {
+			f.FileName = fileName(resp, f.Name)
+		}
+	}
+	return ranges, nil
 }
 
-// Downloader : pausable downloader
-type Downloader struct {
-	URL       string // URL Source
-	Dir       string // Directory to store the file
-	Name      string // Name of file
-	Source    string // Name of the Source
-	Size      int64  // Size of the file
-	Completed bool   // Status of Download
+// fileName : the name of the file served by resp, from its Content-Disposition or url, or else name
+// Names that would lead out of the folder of the file are not used
+func fileName(resp *http.Response, name string) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if filename := filepath.Base(params["filename"]); params["filename"] != "" && safeName(filename) {
+			return filename
+		}
+	}
+	if base := path.Base(resp.Request.URL.Path); path.Ext(base) != "" {
+		if unescaped, err := url.PathUnescape(base); err == nil && safeName(unescaped) {
+			return unescaped
+		}
+		if safeName(base) {
+			return base
+		}
+	}
+	if name != "" {
+		if name = separators.Replace(name); !safeName(name) {
+			name = "Untitled"
+		}
+	}
+	if exts, _ := mime.ExtensionsByType(resp.Header.Get("Content-Type")); len(exts) > 0 {
+		return name + exts[0]
+	}
+	return name
 }
 
-//TODO:  Check if Download is completed and ask for redownload confirmation
+// safeName : reports whether name is a single path element, which stays in the folder it is joined to
+func safeName(name string) bool {
+	return name != "." && name != ".." && !strings.ContainsAny(name, "/\\") && filepath.Base(name) == name
+}
 
-// DownloadFile : Download Files using Annie Downloader
-func (f *Downloader) DownloadFile() error {
-	var (
-		err  error
-		data []*types.Data
-	)
+// loadState : the saved progress of the partial download if it is for the same file
+func (f *Downloader) loadState(stateFile string) *partState {
+	content, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		return nil
+	}
+	var state partState
+	if err = json.Unmarshal(content, &state); err != nil {
+		log.Debugf("Ignoring invalid download state %s: %v", stateFile, err)
+		return nil
+	}
+	if state.Size != f.Size || state.Size <= 0 || len(state.Segments) == 0 {
+		return nil
+	}
+	return &state
+}
 
-	// Extract data to be downloaded with the streams
-	data, err = Extract(f.URL, f.Source)
+// saveState : save the progress of the partial download
+func saveState(stateFile string, state *partState, mu *sync.Mutex) error {
+	mu.Lock()
+	content, err := json.Marshal(state)
+	mu.Unlock()
 	if err != nil {
 		return err
 	}
+	tmp := stateFile + ".tmp"
+	if err = ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, stateFile)
+}
 
-	err = os.MkdirAll(f.Dir, os.ModePerm)
+// newState : split a file of size in segments
+func (f *Downloader) newState(ranges bool) *partState {
+	state := &partState{URL: f.URL, Size: f.Size}
+	if !ranges || f.Size <= 0 {
+		// A single request for the whole file, of unknown size if End is -1
+		state.Segments = []*segment{{Start: 0, End: f.Size - 1}}
+		return state
+	}
+	n := f.Segments
+	if n <= 0 {
+		n = DefaultSegments
+	}
+	if limit := (f.Size + minSegmentSize - 1) / minSegmentSize; int64(n) > limit {
+		n = int(limit)
+	}
+	segmentSize := f.Size / int64(n)
+	for i := 0; i < n; i++ {
+		s := &segment{Start: int64(i) * segmentSize, End: int64(i+1)*segmentSize - 1}
+		if i == n-1 {
+			s.End = f.Size - 1
+		}
+		state.Segments = append(state.Segments, s)
+	}
+	return state
+}
+
+// DownloadFile : Download the file, resuming a previous partial download if any
+// It returns early with the error of ctx once ctx is done, leaving the partial file to be resumed
+func (f *Downloader) DownloadFile(ctx context.Context) error {
+	ranges, err := f.probe(ctx)
 	if err != nil {
 		return err
 	}
+	if err = os.MkdirAll(f.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	file := f.Path()
+	if info, err := os.Stat(file); err == nil && f.Size > 0 && info.Size() == f.Size {
+		log.Infof("%s is already downloaded to %s", f.Name, file)
+		f.Completed = true
+		return nil
+	}
 
-	if f.Size == 0 {
-		f.Size, err = request.Size(f.URL, f.URL)
-		if err != nil {
+	partFile, stateFile := file+".part", file+".part.json"
+	var state *partState
+	if ranges {
+		state = f.loadState(stateFile)
+	}
+	if state == nil {
+		state = f.newState(ranges)
+		// Without ranges or a matching state, the partial file cannot be trusted
+		os.Remove(partFile)
+		os.Remove(stateFile)
+	}
+	out, err := os.OpenFile(partFile, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if f.Size > 0 {
+		if err = out.Truncate(f.Size); err != nil {
+			out.Close()
 			return err
 		}
 	}
 
-	for _, item := range data {
-		if item.Err != nil {
-			// if this error occurs, the preparation step is normal, but the data extraction is wrong.
-			// the data is an empty struct.
-			return item.Err
+	f.downloaded = 0
+	for _, s := range state.Segments {
+		f.downloaded += s.Done
+	}
+	if f.OnStart != nil {
+		f.OnStart(file, f.Size)
+	}
+	err = f.downloadSegments(ctx, out, state, stateFile, ranges)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = f.verify(partFile); err != nil {
+		// A corrupted file is downloaded again from scratch on the next attempt
+		os.Remove(partFile)
+		os.Remove(stateFile)
+		return err
+	}
+	if err = os.Rename(partFile, file); err != nil {
+		return err
+	}
+	os.Remove(stateFile)
+	f.Completed = true
+	log.Infof("Downloaded %s to %s", f.Name, file)
+	return nil
+}
+
+// downloadSegments : download all unfinished segments in parallel, saving their progress regularly
+// Offsets received from Priority split the segment they are in, so that they are downloaded next
+func (f *Downloader) downloadSegments(ctx context.Context, out *os.File, state *partState, stateFile string, ranges bool) error {
+	var (
+		mu     sync.Mutex // Guards the segments and the workers downloading them
+		active int        // Workers still downloading a segment
+		errs   []error
+		done   = make(chan struct{}) // Closed once every worker returned
+		stop   = make(chan struct{})
+	)
+	segmentCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// start : download s with a new worker, called with mu held
+	start := func(s *segment) {
+		active++
+		go func() {
+			err := f.downloadSegment(segmentCtx, out, s, &mu, ranges)
+			if err != nil {
+				// Other segments cannot complete the file anymore
+				cancel()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if active--; active == 0 {
+				close(done)
+			}
+		}()
+	}
+
+	// Report progress and save the state until all segments are done
+	// The state is saved even without ranges so that the bytes on disk are known while streaming
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			if f.OnProgress != nil {
+				f.OnProgress(atomic.LoadInt64(&f.downloaded), f.Size)
+			}
+			select {
+			case <-stop:
+				return
+			case offset := <-f.Priority:
+				if ranges {
+					mu.Lock()
+					if active > 0 && segmentCtx.Err() == nil {
+						if s := state.split(offset); s != nil {
+							log.Debugf("Downloading %s from %d first", f.Name, offset)
+							start(s)
+						}
+					}
+					mu.Unlock()
+				}
+			case <-ticker.C:
+				if err := saveState(stateFile, state, &mu); err != nil {
+					log.Debugf("Could not save download state: %v", err)
+				}
+			}
 		}
-		movieDownloader := downloader.New(downloader.Options{
-			OutputPath: f.Dir,
-			Stream:     "default",
-		})
-		err = movieDownloader.Download(item)
-		if err != nil {
+	}()
+
+	mu.Lock()
+	for _, s := range state.Segments {
+		if s.End >= 0 && s.Start+s.Done > s.End {
+			continue
+		}
+		start(s)
+	}
+	if active == 0 {
+		close(done)
+	}
+	mu.Unlock()
+	<-done
+	close(stop)
+	<-reported
+
+	if err := saveState(stateFile, state, &mu); err != nil {
+		log.Debugf("Could not save download state: %v", err)
+	}
+	if f.OnProgress != nil {
+		f.OnProgress(atomic.LoadInt64(&f.downloaded), f.Size)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, err := range errs {
+		if !errors.Is(err, context.Canceled) {
 			return err
 		}
 	}
-	log.Infof("Downloaded %s to %s", f.Name, f.Dir)
 	return nil
 }
 
-// DownloadMovie : Download the movie
-func DownloadMovie(movie *engine.Movie, outputDir string) error {
-	url := movie.DownloadLink.String()
-	downloadHandler := &Downloader{
-		URL:    url,
-		Name:   movie.Title,
-		Source: movie.Source,
+// split : the new segment downloading the file from offset, cut from the end of the unfinished
+// segment offset is in. It returns nil when the download of that segment is about to reach offset,
+// or when offset is already downloaded
+func (state *partState) split(offset int64) *segment {
+	for _, s := range state.Segments {
+		next := s.Start + s.Done
+		if s.End < 0 || offset < next || offset > s.End {
+			continue
+		}
+		if offset-next < minSegmentSize {
+			return nil
+		}
+		split := &segment{Start: offset, End: s.End}
+		s.End = offset - 1
+		state.Segments = append(state.Segments, split)
+		return split
 	}
+	return nil
+}
 
-	downloadHandler.Dir = path.Join(outputDir, downloadHandler.Name)
-	downloadListFile := path.Join(viper.GetString("gophie_cache"), "downloadList.json")
+// downloadSegment : download a segment, retrying with exponential backoff when the connection drops
+func (f *Downloader) downloadSegment(ctx context.Context, out *os.File, s *segment, mu *sync.Mutex, ranges bool) error {
+	retries := f.Retries
+	if retries <= 0 {
+		retries = DefaultRetries
+	}
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		mu.Lock()
+		before := s.Done
+		mu.Unlock()
+		err := f.fetchSegment(ctx, out, s, mu, ranges)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		mu.Lock()
+		progressed := s.Done > before
+		mu.Unlock()
+		if progressed {
+			// The connection dropped after some progress, start counting retries again
+			attempt = 0
+			backoff = retryBackoff
+		}
+		if attempt >= retries {
+			return err
+		}
+		log.Debugf("Retrying %s in %v: %v", f.Name, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
 
-	var (
-		downloads     []Downloader
-		downloadsFile *os.File
-		err           error
-		size          int64
-	)
-	//get file size
-	size, err = request.Size(url, url)
+// fetchSegment : request the rest of a segment and write it to out at its offset
+func (f *Downloader) fetchSegment(ctx context.Context, out *os.File, s *segment, mu *sync.Mutex, ranges bool) error {
+	req, err := f.newRequest(ctx, http.MethodGet)
 	if err != nil {
 		return err
 	}
-	downloadHandler.Size = size
+	mu.Lock()
+	offset, end := s.Start+s.Done, s.End
+	mu.Unlock()
+	if !ranges && offset > 0 {
+		// The server can only send the whole file again
+		atomic.AddInt64(&f.downloaded, -offset)
+		mu.Lock()
+		s.Done = 0
+		mu.Unlock()
+		offset = 0
+	}
+	if ranges {
+		if end >= 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, end))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+	}
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case ranges && resp.StatusCode == http.StatusPartialContent:
+	case !ranges && resp.StatusCode == http.StatusOK:
+	default:
+		return fmt.Errorf("%s: unexpected response %s", f.URL, resp.Status)
+	}
 
-	// Load Downloads if file exists
-	if _, err = os.Stat(downloadListFile); err == nil {
-		downloadsFile, err = os.OpenFile(downloadListFile, os.O_RDONLY, os.ModePerm)
-		if err != nil {
-			return err
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		// The end of the segment moves when it is split
+		mu.Lock()
+		end = s.End
+		mu.Unlock()
+		if n > 0 {
+			if end >= 0 && offset+int64(n) > end+1 {
+				n = int(end + 1 - offset)
+			}
+			if _, err = out.WriteAt(buf[:n], offset); err != nil {
+				return err
+			}
+			offset += int64(n)
+			atomic.AddInt64(&f.downloaded, int64(n))
+			mu.Lock()
+			s.Done += int64(n)
+			mu.Unlock()
+			if end >= 0 && offset > end {
+				return nil
+			}
 		}
-		dec := json.NewDecoder(downloadsFile)
-		if err = dec.Decode(&downloads); err != nil {
-			return err
+		if readErr == io.EOF {
+			if end >= 0 && offset <= end {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
 		}
-		downloadsFile.Close()
-	} else if os.IsNotExist(err) {
-		// Create Download List File if it does not exists
-		_, err = os.Create(downloadListFile)
-		if err != nil {
-			return err
+		if readErr != nil {
+			return readErr
 		}
-	} else {
+	}
+}
+
+// verify : check the size and checksum of the downloaded file
+func (f *Downloader) verify(file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
 		return err
 	}
+	if f.Size > 0 && info.Size() != f.Size {
+		return fmt.Errorf("%w: %d bytes instead of %d", ErrSizeMismatch, info.Size(), f.Size)
+	}
+	if f.Size <= 0 {
+		f.Size = info.Size()
+	}
+	if f.Checksum == "" {
+		return nil
+	}
+	algorithm, expected := "sha256", f.Checksum
+	if i := strings.Index(f.Checksum, ":"); i >= 0 {
+		algorithm, expected = strings.ToLower(f.Checksum[:i]), f.Checksum[i+1:]
+	}
+	var h hash.Hash
+	switch algorithm {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return fmt.Errorf("unsupported checksum algorithm %s", algorithm)
+	}
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, err = io.Copy(h, in); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w: %s instead of %s", ErrChecksumMismatch, actual, expected)
+	}
+	return nil
+}
+
+// ShowProgress : render the progress of the jobs of manager on stderr until the returned
+// function is called
+func ShowProgress(manager *Manager) func() {
+	events, cancel := manager.Subscribe()
+	bar := NewProgressBar(os.Stderr)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bar.Follow(events)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// FormatBytes : a human readable size
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// DownloadMovie : Download the movie through the download queue, returning once it is done
+// A previous download of the same link is continued. If it is already completed, an error wrapping
+// ErrAlreadyDownloaded is returned unless force is set, in which case the file is downloaded again.
+// An interrupted download is queued again so that `gophie resume` continues it
+func DownloadMovie(movie *engine.Movie, outputDir string, force bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	manager := NewManager(DefaultStore(), viper.GetInt("parallel-downloads"))
+
+	job := NewJob(movie, outputDir)
+	job.Subtitles = viper.GetBool("subtitles")
+	job, err := previousJob(ctx, manager.Store(), job, force)
+	if err != nil {
+		return err
+	}
+	stopProgress := ShowProgress(manager)
+	defer stopProgress()
+	_, err = manager.Download(ctx, job)
+	return err
+}
 
-	// Load Config
-	// Check for existing downloads
-	exist := func() bool {
-		for _, downloader := range downloads {
-			if downloader.URL == downloadHandler.URL {
-				return true
+// Queue : add job to the queue of manager, or queue a previous download of the same file again
+// so that it continues from its partial file. An error wrapping ErrAlreadyDownloaded is returned
+// if the file is already downloaded, unless force is set
+func (m *Manager) Queue(ctx context.Context, job Job, force bool) (Job, error) {
+	job, err := previousJob(ctx, m.store, job, force)
+	if err != nil {
+		return job, err
+	}
+	return m.Add(job)
+}
+
+// previousJob : the job of a previous download of the same file as job, or job itself if there is none
+// A previous job that is queued or running is returned unchanged. An error wrapping ErrAlreadyDownloaded
+// is returned if the previous download is completed, unless force is set, in which case its files are
+// removed so that it is downloaded again. A subtitle asked for a completed download is still downloaded
+func previousJob(ctx context.Context, store *Store, job Job, force bool) (Job, error) {
+	existing, err := store.Find(job)
+	if errors.Is(err, ErrJobNotFound) {
+		return job, nil
+	}
+	if err != nil {
+		return job, err
+	}
+	// Jobs waiting in the queue or being downloaded are left as they are
+	if existing.active() {
+		return existing, nil
+	}
+	if job.Subtitles {
+		existing.Subtitles = true
+	}
+	if job.SubtitleURL != "" {
+		existing.SubtitleURL = job.SubtitleURL
+	}
+	if existing.Completed() {
+		if !force {
+			if existing.Subtitles {
+				downloadJobSubtitle(ctx, &existing, http.DefaultClient)
+				if err = store.Put(existing); err != nil {
+					return existing, err
+				}
 			}
+			return existing, fmt.Errorf("%s %w to %s", job.Title, ErrAlreadyDownloaded, existing.File)
 		}
-		return false
-	}()
-	// if file is not in Downloads cache, add and start the download
-	// in the download cache, append it and save
-	if !exist {
-		log.Debug("Movie getting added to Download list")
-		downloads = append(downloads, *downloadHandler)
-		downloadsFile, err = os.OpenFile(downloadListFile, os.O_WRONLY, os.ModePerm)
-		enc := json.NewEncoder(downloadsFile)
-		if err = enc.Encode(downloads); err != nil {
+		if err = existing.RemoveFiles(); err != nil {
+			return existing, err
+		}
+	}
+	// Continue with the fresh link
+	existing.URL = job.URL
+	return existing, nil
+}
+
+// DownloadEpisodes : Download episodes of series through the download queue with up to
+// parallel-downloads at a time, returning once they are done
+// Episodes that are already downloaded are skipped unless force is set
+func DownloadEpisodes(series *engine.Movie, episodes []engine.Episode, outputDir string, force bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	manager := NewManager(DefaultStore(), viper.GetInt("parallel-downloads"))
+
+	var queued []Job
+	for _, episode := range episodes {
+		job := NewEpisodeJob(series, episode, outputDir)
+		job.Subtitles = viper.GetBool("subtitles")
+		job, err := manager.Queue(ctx, job, force)
+		if errors.Is(err, ErrAlreadyDownloaded) {
+			log.Infof("Skipping %v", err)
+			continue
+		}
+		if err != nil {
 			return err
 		}
+		queued = append(queued, job)
+	}
+	if len(queued) == 0 {
+		log.Info("All episodes are already downloaded")
+		return nil
 	}
-	downloadsFile.Close()
+	log.Infof("Downloading %d episodes of %s", len(queued), series.Title)
+	// Interrupted downloads are queued again
+	stopProgress := ShowProgress(manager)
+	manager.Run(ctx)
+	stopProgress()
 
-	return downloadHandler.DownloadFile()
+	var failed int
+	for _, job := range queued {
+		if job, err := manager.Store().Get(job.ID); err == nil && job.State != StateCompleted {
+			failed++
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d episodes of %s were not downloaded, see `gophie downloads list`", failed, len(queued), series.Title)
+	}
+	return nil
 }
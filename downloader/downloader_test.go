@@ -1,16 +1,1035 @@
 package downloader
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/go-phie/gophie/engine"
+	"github.com/gocolly/colly/v2"
+	"github.com/spf13/viper"
 )
 
-var f = &Downloader{
-	URL:    "http://cdn2.mhpbooks.com/2016/02/google.jpg",
-	Name:   "Test-image",
-	Source: "NetNaija",
+// cutWriter : a ResponseWriter that drops the connection after limit bytes of the body
+type cutWriter struct {
+	http.ResponseWriter
+	limit int
+}
+
+func (w *cutWriter) Write(b []byte) (int, error) {
+	if len(b) < w.limit {
+		w.limit -= len(b)
+		return w.ResponseWriter.Write(b)
+	}
+	n, _ := w.ResponseWriter.Write(b[:w.limit])
+	w.ResponseWriter.(http.Flusher).Flush()
+	conn, _, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+	return n, io.ErrClosedPipe
+}
+
+// fileServer : serve content as movie.mp4, with range requests unless noRanges is set
+// The first drops downloads are cut short to simulate dropped connections
+func fileServer(content []byte, noRanges bool, drops int32) (*httptest.Server, *int32) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if noRanges {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content)
+			return
+		}
+		if r.Header.Get("Range") != "bytes=0-0" && atomic.AddInt32(&drops, -1) >= 0 {
+			w = &cutWriter{ResponseWriter: w, limit: 1000}
+		}
+		http.ServeContent(w, r, "movie.mp4", time.Time{}, bytes.NewReader(content))
+	}))
+	return ts, &requests
+}
+
+func TestDownloadFile(t *testing.T) {
+	defer func(previous time.Duration) { retryBackoff = previous }(retryBackoff)
+	retryBackoff = time.Millisecond
+	content := bytes.Repeat([]byte("0123456789"), minSegmentSize/2)
+	sum := sha256.Sum256(content)
+	checksum := "sha256:" + hex.EncodeToString(sum[:])
+
+	for _, test := range []struct {
+		name     string
+		noRanges bool
+		drops    int32
+	}{
+		{name: "segments"},
+		{name: "no ranges", noRanges: true},
+		{name: "dropped connections", drops: 3},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ts, _ := fileServer(content, test.noRanges, test.drops)
+			defer ts.Close()
+			var progress int64
+			d := &Downloader{
+				URL:        ts.URL + "/files/movie.mp4",
+				Dir:        t.TempDir(),
+				Name:       "Movie",
+				Checksum:   checksum,
+				OnProgress: func(downloaded, size int64) { progress = downloaded },
+			}
+			if err := d.DownloadFile(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(filepath.Join(d.Dir, "movie.mp4"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) || !d.Completed || d.Size != int64(len(content)) {
+				t.Errorf("Expected complete file of %d bytes, got %d bytes", len(content), len(got))
+			}
+			if progress != int64(len(content)) {
+				t.Errorf("Expected final progress of %d, got %d", len(content), progress)
+			}
+			if _, err = os.Stat(d.Path() + ".part.json"); !os.IsNotExist(err) {
+				t.Errorf("Expected download state to be removed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	content := bytes.Repeat([]byte("abcdefghij"), minSegmentSize)
+	ts, requests := fileServer(content, false, 0)
+	defer ts.Close()
+	dir := t.TempDir()
+
+	// A download interrupted halfway through each of its segments
+	half := int64(len(content)) / 4
+	state := partState{URL: ts.URL + "/movie.mp4", Size: int64(len(content))}
+	for i := int64(0); i < 2; i++ {
+		state.Segments = append(state.Segments, &segment{Start: i * 2 * half, End: (i+1)*2*half - 1, Done: half})
+	}
+	partial := make([]byte, len(content))
+	copy(partial[:half], content[:half])
+	copy(partial[2*half:3*half], content[2*half:3*half])
+	if err := ioutil.WriteFile(filepath.Join(dir, "movie.mp4.part"), partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(state)
+	if err := ioutil.WriteFile(filepath.Join(dir, "movie.mp4.part.json"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var resumed int64 = -1
+	d := &Downloader{
+		URL:  ts.URL + "/movie.mp4",
+		Dir:  dir,
+		Name: "Movie",
+		OnProgress: func(downloaded, size int64) {
+			if resumed < 0 {
+				resumed = downloaded
+			}
+		},
+	}
+	if err := d.DownloadFile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if resumed != 2*half {
+		t.Errorf("Expected download to resume from %d bytes, got %d", 2*half, resumed)
+	}
+	got, _ := ioutil.ReadFile(d.Path())
+	if !bytes.Equal(got, content) {
+		t.Error("Expected resumed file to match the content")
+	}
+	// One probe and one request for the rest of each segment
+	if *requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", *requests)
+	}
+}
+
+func TestDownloadFileChecksumMismatch(t *testing.T) {
+	ts, _ := fileServer([]byte("not the movie"), false, 0)
+	defer ts.Close()
+	d := &Downloader{
+		URL:      ts.URL + "/movie.mp4",
+		Dir:      t.TempDir(),
+		Checksum: "md5:00000000000000000000000000000000",
+	}
+	if err := d.DownloadFile(context.Background()); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := os.Stat(d.Path() + ".part"); !os.IsNotExist(err) {
+		t.Errorf("Expected corrupted file to be removed, got %v", err)
+	}
+}
+
+func TestStoreConcurrentUpdates(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "downloads.json")
+	var wg sync.WaitGroup
+	// Separate stores on the same file behave like separate processes
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := NewStore(file).Put(Job{ID: string(rune('a' + i)), State: StateQueued}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	jobs, err := NewStore(file).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 20 {
+		t.Errorf("Expected 20 jobs, got %v", len(jobs))
+	}
+	if err = NewStore(file).Delete("a"); err != nil {
+		t.Error(err)
+	}
+	if _, err = NewStore(file).Get("a"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+}
+
+// waitForState : wait until the job with id reaches state
+func waitForState(t *testing.T, store *Store, id string, state State) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := store.Get(id)
+		if err == nil && job.State == state {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to be %s, got %s (%v)", id, state, job.State, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestImportLegacyDownloads(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "downloads.json"))
+	if err := store.Put(Job{ID: "known", Title: "Known", URL: "https://example.com/known.mp4", State: StateCompleted}); err != nil {
+		t.Fatal(err)
+	}
+	list := filepath.Join(dir, "downloadList.json")
+	legacy := `[{"URL": "https://example.com/movie.mp4", "Dir": "/downloads/Movie", "Name": "Movie", "Source": "FzMovies", "Size": 42, "Completed": false},
+		{"URL": "https://example.com/known.mp4", "Dir": "/downloads/Known", "Name": "Known", "Source": "FzMovies"}]`
+	if err := ioutil.WriteFile(list, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := store.importLegacyDownloads(list); err != nil || n != 1 {
+		t.Fatalf("Expected 1 download to be imported, got %d and %v", n, err)
+	}
+	job, err := store.Find(Job{URL: "https://example.com/movie.mp4"})
+	if err != nil || job.State != StatePaused || job.Title != "Movie" || job.Dir != "/downloads/Movie" || job.Engine != "fzmovies" || job.Size != 42 {
+		t.Errorf("Expected a paused job for the download, got %+v and %v", job, err)
+	}
+	if jobs, _ := store.List(); len(jobs) != 2 {
+		t.Errorf("Expected known links not to be imported again, got %d jobs", len(jobs))
+	}
+	if _, err = os.Stat(list + ".imported"); err != nil {
+		t.Errorf("Expected the old list to be renamed, got %v", err)
+	}
+	if _, err = store.importLegacyDownloads(list); !os.IsNotExist(err) {
+		t.Errorf("Expected the old list to be imported once, got %v", err)
+	}
+}
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "downloads.json"))
+	manager := NewManager(store, 2)
+	var (
+		mu               sync.Mutex
+		active, maxCount int
+		release          = make(chan struct{})
+	)
+	manager.Runner = func(ctx context.Context, job *Job) error {
+		mu.Lock()
+		active++
+		if active > maxCount {
+			maxCount = active
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+		if job.Title == "broken" {
+			return errors.New("no such file")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-release:
+			job.Size = 42
+			job.File = filepath.Join(dir, job.ID)
+			return ioutil.WriteFile(job.File, make([]byte, job.Size), 0644)
+		}
+	}
+
+	var ids []string
+	for _, title := range []string{"one", "two", "three", "broken"} {
+		job, err := manager.Add(Job{Title: title})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, job.ID)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		manager.Serve(ctx)
+		close(done)
+	}()
+
+	waitForState(t, store, ids[0], StateRunning)
+	waitForState(t, store, ids[1], StateRunning)
+	if job, _ := store.Get(ids[2]); job.State != StateQueued {
+		t.Errorf("Expected third job to wait for a free worker, got %s", job.State)
+	}
+	if err := manager.Pause(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, store, ids[0], StatePaused)
+	waitForState(t, store, ids[2], StateRunning)
+
+	close(release)
+	if job := waitForState(t, store, ids[1], StateCompleted); job.Size != 42 {
+		t.Errorf("Expected size set by the runner, got %v", job.Size)
+	}
+	waitForState(t, store, ids[2], StateCompleted)
+	if job := waitForState(t, store, ids[3], StateFailed); job.Error != "no such file" {
+		t.Errorf("Expected error of the runner, got %q", job.Error)
+	}
+
+	if err := manager.Resume(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, store, ids[0], StateCompleted)
+	if err := manager.Resume(ids[0]); err == nil {
+		t.Error("Expected completed download not to be resumed")
+	}
+	if err := manager.Remove(ids[3]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ids[3]); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected removed job to be deleted, got %v", err)
+	}
+	cancel()
+	<-done
+	if maxCount > 2 {
+		t.Errorf("Expected at most 2 parallel downloads, got %v", maxCount)
+	}
+}
+
+func TestQueueActiveJob(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "downloads.json"))
+	// Managers sharing a store are like the managers of several processes
+	running, other := NewManager(store, 1), NewManager(store, 1)
+	release := make(chan struct{})
+	running.Runner = func(ctx context.Context, job *Job) error {
+		<-release
+		return nil
+	}
+	other.Runner = func(ctx context.Context, job *Job) error {
+		t.Errorf("Expected %s not to be downloaded twice", job.Title)
+		return nil
+	}
+
+	job, err := running.Queue(context.Background(), Job{Title: "Movie", URL: "https://example.com/movie.mp4"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := other.Queue(context.Background(), Job{Title: "Movie", URL: "https://example.com/movie.mp4"}, false)
+	if err != nil || queued.ID != job.ID || !queued.CreatedAt.Equal(job.CreatedAt) {
+		t.Errorf("Expected the queued job unchanged, got %+v and %v", queued, err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := running.DownloadQueued(context.Background(), job.ID)
+		done <- err
+	}()
+	waitForState(t, store, job.ID, StateRunning)
+
+	if queued, err = other.Queue(context.Background(), job, false); err != nil || queued.State != StateRunning {
+		t.Errorf("Expected the running job unchanged, got %s and %v", queued.State, err)
+	}
+	if err = other.Resume(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = other.DownloadQueued(context.Background(), job.ID); err == nil {
+		t.Error("Expected a job running in another manager not to be claimed")
+	}
+	if err = other.Pause(job.ID); err == nil {
+		t.Error("Expected a job running in another manager not to be paused")
+	}
+	close(release)
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// Jobs left running by a process that stopped are taken over
+	err = store.Update(func(jobs map[string]*Job) error {
+		jobs[job.ID].State = StateRunning
+		jobs[job.ID].UpdatedAt = time.Now().Add(-heartbeatTimeout)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued, err = other.Add(job); err != nil || queued.State != StateQueued || !queued.CreatedAt.Equal(job.CreatedAt) {
+		t.Errorf("Expected the stale job queued again in its place, got %+v and %v", queued, err)
+	}
+}
+
+func TestDownloadMovieCompletion(t *testing.T) {
+	content := []byte("the whole movie")
+	ts, requests := fileServer(content, false, 0)
+	defer ts.Close()
+	defer func(previous string) { viper.Set("config-dir", previous) }(viper.GetString("config-dir"))
+	viper.Set("config-dir", t.TempDir())
+	outputDir := t.TempDir()
+	link, _ := url.Parse(ts.URL + "/movie.mp4")
+	movie := &engine.Movie{Title: "Movie", DownloadLink: link, Source: "FzMovies"}
+
+	if err := DownloadMovie(movie, outputDir, false); err != nil {
+		t.Fatal(err)
+	}
+	job, err := DefaultStore().Find(Job{URL: link.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !job.Completed() || job.File != filepath.Join(outputDir, "Movie", "movie.mp4") || job.Downloaded() != int64(len(content)) {
+		t.Errorf("Expected completed download to be tracked, got %+v", job)
+	}
+
+	before := atomic.LoadInt32(requests)
+	if err = DownloadMovie(movie, outputDir, false); !errors.Is(err, ErrAlreadyDownloaded) {
+		t.Errorf("Expected ErrAlreadyDownloaded, got %v", err)
+	}
+	if atomic.LoadInt32(requests) != before {
+		t.Error("Expected no request for a completed download")
+	}
+	if err = DownloadMovie(movie, outputDir, true); err != nil {
+		t.Errorf("Expected forced download to succeed: %v", err)
+	}
+
+	// A completed download whose file is gone can be downloaded again
+	os.Remove(job.File)
+	if job, _ = DefaultStore().Get(job.ID); job.Completed() {
+		t.Error("Expected download with a missing file not to be completed")
+	}
+	if err = DownloadMovie(movie, outputDir, false); err != nil {
+		t.Errorf("Expected missing file to be downloaded again: %v", err)
+	}
+	if jobs, _ := DefaultStore().List(); len(jobs) != 1 {
+		t.Errorf("Expected downloads of the same link to share a job, got %d jobs", len(jobs))
+	}
+}
+
+func TestJobDir(t *testing.T) {
+	outputDir := t.TempDir()
+	link, _ := url.Parse("https://example.com/movie.mp4")
+	for title, expected := range map[string]string{
+		"Jumanji (2017)": "Jumanji (2017)",
+		"../../etc":      "..-..-etc",
+		"..":             "Untitled",
+		"AC/DC\\Live":    "AC-DC-Live",
+	} {
+		job := NewJob(&engine.Movie{Title: title, DownloadLink: link}, outputDir)
+		if job.Dir != filepath.Join(outputDir, expected) {
+			t.Errorf("Expected %q in %s, got %s", title, expected, job.Dir)
+		}
+		episode := engine.Episode{Season: 1, Number: 2, Link: link}
+		if job = NewEpisodeJob(&engine.Movie{Title: title}, episode, outputDir); job.Dir != filepath.Join(outputDir, expected) {
+			t.Errorf("Expected episodes of %q in %s, got %s", title, expected, job.Dir)
+		}
+	}
+}
+
+func TestDownloadSubtitles(t *testing.T) {
+	content := []byte("the whole movie")
+	ts, _ := fileServer(content, false, 0)
+	defer ts.Close()
+	defer func(previous string) { viper.Set("config-dir", previous) }(viper.GetString("config-dir"))
+	viper.Set("config-dir", t.TempDir())
+	defer viper.Set("subtitles", false)
+	outputDir := t.TempDir()
+	link, _ := url.Parse(ts.URL + "/movie.mp4")
+	subtitle, _ := url.Parse(ts.URL + "/subtitles/english.srt")
+	movie := &engine.Movie{Title: "Movie", DownloadLink: link, SubtitleLink: subtitle, Source: "NetNaija"}
+	subtitleFile := filepath.Join(outputDir, "Movie", "movie.srt")
+
+	if err := DownloadMovie(movie, outputDir, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(subtitleFile); !os.IsNotExist(err) {
+		t.Errorf("Expected no subtitle unless asked for, got %v", err)
+	}
+
+	// The subtitle of a completed download is still downloaded
+	viper.Set("subtitles", true)
+	if err := DownloadMovie(movie, outputDir, false); !errors.Is(err, ErrAlreadyDownloaded) {
+		t.Errorf("Expected ErrAlreadyDownloaded, got %v", err)
+	}
+	if got, _ := ioutil.ReadFile(subtitleFile); !bytes.Equal(got, content) {
+		t.Errorf("Expected subtitle next to the movie, got %q", got)
+	}
+	job, _ := DefaultStore().Find(Job{URL: link.String()})
+	if job.SubtitleFile != subtitleFile {
+		t.Errorf("Expected subtitle to be tracked, got %q", job.SubtitleFile)
+	}
+	if err := job.RemoveFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(subtitleFile); !os.IsNotExist(err) {
+		t.Error("Expected subtitle to be removed with the movie")
+	}
+
+	if err := DownloadMovie(movie, outputDir, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadFile(subtitleFile); !bytes.Equal(got, content) {
+		t.Errorf("Expected subtitle downloaded with the movie, got %q", got)
+	}
+}
+
+func TestDownloadZippedSubtitle(t *testing.T) {
+	var archive bytes.Buffer
+	zipped := zip.NewWriter(&archive)
+	for name, content := range map[string]string{"readme.txt": "read me", "subs/english.srt": "1\nsubtitle"} {
+		w, err := zipped.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, content)
+	}
+	if err := zipped.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(archive.Bytes())
+	}))
+	defer ts.Close()
+	file := filepath.Join(t.TempDir(), "movie.mp4")
+
+	subtitle, err := DownloadSubtitle(context.Background(), http.DefaultClient, ts.URL+"/english.zip", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := strings.TrimSuffix(file, ".mp4") + ".srt"; subtitle != expected {
+		t.Errorf("Expected subtitle %s, got %s", expected, subtitle)
+	}
+	if got, _ := ioutil.ReadFile(subtitle); string(got) != "1\nsubtitle" {
+		t.Errorf("Expected the subtitle in the archive, got %q", got)
+	}
+	if entries, _ := ioutil.ReadDir(filepath.Dir(file)); len(entries) != 1 {
+		t.Errorf("Expected only the subtitle to be kept, got %d files", len(entries))
+	}
+}
+func TestDownloadEpisodes(t *testing.T) {
+	content := []byte("the whole episode")
+	ts, requests := fileServer(content, false, 0)
+	defer ts.Close()
+	defer func(previous string) { viper.Set("config-dir", previous) }(viper.GetString("config-dir"))
+	viper.Set("config-dir", t.TempDir())
+	outputDir := t.TempDir()
+	series := &engine.Movie{Title: "Flower of Evil", Source: "KDramaHood", SDownloadLink: map[string]*url.URL{}}
+	for i := 1; i <= 3; i++ {
+		link, _ := url.Parse(fmt.Sprintf("%s/E0%d.mp4", ts.URL, i))
+		series.SDownloadLink[fmt.Sprintf("Flower of Evil Episode %d", i)] = link
+	}
+	episodes := series.Episodes()
+
+	if err := DownloadEpisodes(series, episodes[:2], outputDir, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Flower of Evil S01E01.mp4", "Flower of Evil S01E02.mp4"} {
+		if got, _ := ioutil.ReadFile(filepath.Join(outputDir, "Flower of Evil", name)); !bytes.Equal(got, content) {
+			t.Errorf("Expected %s to be downloaded, got %q", name, got)
+		}
+	}
+
+	// Downloaded episodes are skipped
+	before := atomic.LoadInt32(requests)
+	if err := DownloadEpisodes(series, episodes[:2], outputDir, false); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(requests) != before {
+		t.Error("Expected no request for downloaded episodes")
+	}
+	if err := DownloadEpisodes(series, episodes, outputDir, false); err != nil {
+		t.Fatal(err)
+	}
+	jobs, _ := DefaultStore().List()
+	if len(jobs) != 3 {
+		t.Fatalf("Expected a download per episode, got %d", len(jobs))
+	}
+	for _, job := range jobs {
+		if !job.Completed() || job.Season != 1 || job.Episode == 0 {
+			t.Errorf("Expected completed episode, got %+v", job)
+		}
+	}
+}
+
+// detailEngine : an engine whose detail pages link to the file in a.download
+type detailEngine struct {
+	engine.Props
+}
+
+func (e *detailEngine) String() string { return e.Name }
+
+func (e *detailEngine) Search(ctx context.Context, param ...string) (engine.SearchResult, error) {
+	return engine.SearchResult{}, nil
+}
+
+func (e *detailEngine) List(ctx context.Context, page int) (engine.SearchResult, error) {
+	return engine.SearchResult{}, nil
+}
+
+func (e *detailEngine) GetParseAttrs(req *engine.Request) (string, string, error) {
+	return "body", "a", nil
+}
+
+func (e *detailEngine) ParseSingleMovie(req *engine.Request, el *colly.HTMLElement, index int) (engine.Movie, error) {
+	return engine.Movie{}, nil
+}
+
+func (e *detailEngine) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]engine.Movie) {
+	downloadCollector.OnHTML("a.download", func(el *colly.HTMLElement) {
+		movie := &(*movies)[engine.GetMovieIndexFromCtx(el.Request)]
+		movie.DownloadLink, _ = url.Parse(el.Request.AbsoluteURL(el.Attr("href")))
+	})
+}
+
+func TestRefreshExpiredLink(t *testing.T) {
+	content := []byte("the whole movie")
+	token := int32(1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/movie.html", func(w http.ResponseWriter, r *http.Request) {
+		// Every visit gives a new token
+		fmt.Fprintf(w, `<html><body><a class="download" href="/file/movie.mp4?token=%d">Download</a></body></html>`,
+			atomic.AddInt32(&token, 1))
+	})
+	mux.HandleFunc("/file/movie.mp4", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") != strconv.Itoa(int(atomic.LoadInt32(&token))) {
+			http.Error(w, "token expired", http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "movie.mp4", time.Time{}, bytes.NewReader(content))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	engine.Register("refreshtest", func() engine.Engine {
+		return &detailEngine{Props: engine.Props{Name: "RefreshTest"}}
+	})
+
+	job := Job{
+		Title:     "Movie",
+		URL:       ts.URL + "/file/movie.mp4?token=0",
+		Dir:       t.TempDir(),
+		Engine:    "refreshtest",
+		DetailURL: ts.URL + "/movie.html",
+	}
+	if err := DownloadJob(context.Background(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.URL != ts.URL+"/file/movie.mp4?token=2" {
+		t.Errorf("Expected refreshed link, got %s", job.URL)
+	}
+	if got, _ := ioutil.ReadFile(job.File); !bytes.Equal(got, content) {
+		t.Errorf("Expected file downloaded with the refreshed link, got %q", got)
+	}
+
+	job.URL = ts.URL + "/file/movie.mp4?token=0"
+	job.Dir = t.TempDir()
+	job.DetailURL = ""
+	if err := DownloadJob(context.Background(), &job); !errors.Is(err, ErrLinkExpired) {
+		t.Errorf("Expected ErrLinkExpired without a detail page, got %v", err)
+	}
+
+	// Streams of jobs of the queue save the refreshed link for their download
+	store := NewStore(filepath.Join(t.TempDir(), "downloads.json"))
+	proxy := NewProxy(NewManager(store, 1))
+	proxy.Client = ts.Client()
+	job = Job{ID: "expired", Title: "Movie", URL: job.URL, Engine: "refreshtest", DetailURL: ts.URL + "/movie.html", State: StatePaused}
+	if err := store.Put(job); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	proxy.ServeJob(rec, httptest.NewRequest(http.MethodGet, "/expired", nil), job.ID)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), content) {
+		t.Errorf("Expected the movie streamed with the refreshed link, got %d %q", rec.Code, rec.Body)
+	}
+	refreshed := fmt.Sprintf("%s/file/movie.mp4?token=%d", ts.URL, atomic.LoadInt32(&token))
+	if stored, _ := store.Get(job.ID); stored.URL != refreshed {
+		t.Errorf("Expected refreshed link %s in the store, got %s", refreshed, stored.URL)
+	}
+	if len(proxy.jobs) != 0 {
+		t.Errorf("Expected jobs of the queue not to be kept by the proxy, got %v", proxy.jobs)
+	}
+}
+
+func TestWatchlist(t *testing.T) {
+	watchlist := NewWatchlist(filepath.Join(t.TempDir(), "watchlist.json"))
+	sub, err := watchlist.Add(Subscription{Engine: "NetNaija", Query: "Flower of Evil"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = watchlist.Add(Subscription{Engine: "netnaija", Query: "flower of evil"}); !errors.Is(err, ErrAlreadyWatched) {
+		t.Errorf("Expected ErrAlreadyWatched, got %v", err)
+	}
+
+	link := func(s string) *url.URL {
+		u, _ := url.Parse("https://example.com/" + s)
+		return u
+	}
+	series := func(episodes int) engine.SearchResult {
+		var season engine.Season
+		for i := 1; i <= episodes; i++ {
+			season.Episodes = append(season.Episodes, engine.Episode{Season: 1, Number: i, Link: link(strconv.Itoa(i))})
+		}
+		return engine.SearchResult{Movies: []engine.Movie{
+			{Title: "Flower of Evil", IsSeries: true, Seasons: []engine.Season{season}},
+			{Title: "Evil Dead", DownloadLink: link("evil-dead")},
+		}}
+	}
+	if releases := sub.NewReleases(series(2)); len(releases) != 0 {
+		t.Errorf("Expected the first check to only record releases, got %d", len(releases))
+	}
+	if len(sub.Seen) != 2 {
+		t.Errorf("Expected the 2 episodes seen, got %v", sub.Seen)
+	}
+	releases := sub.NewReleases(series(3))
+	if len(releases) != 1 || releases[0].Title() != "Flower of Evil S01E03" || releases[0].Link() != "https://example.com/3" {
+		t.Errorf("Expected the third episode to be new, got %+v", releases)
+	}
+	job := releases[0].Job("downloads", "")
+	if job.Season != 1 || job.Episode != 3 || job.Dir != "downloads/Flower of Evil" {
+		t.Errorf("Expected a job for the episode, got %+v", job)
+	}
+
+	if err = watchlist.save(sub); err != nil {
+		t.Fatal(err)
+	}
+	list, err := watchlist.List()
+	if err != nil || len(list) != 1 || len(list[0].Seen) != 3 || list[0].Checked.IsZero() {
+		t.Errorf("Expected the seen episodes to be saved, got %+v %v", list, err)
+	}
+	if err = watchlist.Remove(sub.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = watchlist.Remove(sub.ID); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Errorf("Expected ErrSubscriptionNotFound, got %v", err)
+	}
+}
+
+func TestManagerProgress(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), minSegmentSize/5)
+	ts, _ := fileServer(content, false, 0)
+	defer ts.Close()
+	manager := NewManager(NewStore(filepath.Join(t.TempDir(), "downloads.json")), 1)
+	events, cancel := manager.Subscribe()
+
+	job, err := manager.Download(context.Background(), Job{Title: "Movie", URL: ts.URL + "/movie.mp4", Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	var states []State
+	var last Progress
+	for p := range events {
+		if p.JobID != job.ID {
+			t.Errorf("Expected progress of %s, got %s", job.ID, p.JobID)
+		}
+		if len(states) == 0 || states[len(states)-1] != p.State {
+			states = append(states, p.State)
+		}
+		last = p
+	}
+	if !reflect.DeepEqual(states, []State{StateQueued, StateRunning, StateCompleted}) {
+		t.Errorf("Expected the job to be queued, run and completed, got %v", states)
+	}
+	if last.Downloaded != int64(len(content)) || last.Size != int64(len(content)) || last.Percent() != 100 {
+		t.Errorf("Expected the whole file downloaded, got %+v", last)
+	}
+	if line := progressLine(last); !strings.Contains(line, "completed") {
+		t.Errorf("Expected a completed line, got %q", line)
+	}
+	b, _ := json.Marshal(&last)
+	if !strings.Contains(string(b), `"Percent":100`) {
+		t.Errorf("Expected the percentage in the JSON of progress, got %s", b)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cookie") != "token=1" || r.Header.Get("Referer") != "https://engine.example/movie" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if strings.HasPrefix(r.URL.Path, "/whole") {
+			// A file host that ignores ranges
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content)
+			return
+		}
+		http.ServeContent(w, r, "movie.mkv", time.Time{}, bytes.NewReader(content))
+	}))
+	defer files.Close()
+	proxy := NewProxy(NewManager(NewStore(filepath.Join(t.TempDir(), "downloads.json")), 1))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxy.ServeJob(w, r, strings.TrimPrefix(r.URL.Path, "/"))
+	}))
+	defer ts.Close()
+
+	// Files on the network of the proxy are not served
+	private := proxy.Add(Job{Title: "Movie", URL: files.URL + "/ranges/movie.mkv"})
+	res, err := http.Get(ts.URL + "/" + private.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a file on a private address to be forbidden, got %s", res.Status)
+	}
+	// Jobs that are not streamed for a while are forgotten
+	proxy.jobs[private.ID].used = time.Now().Add(-streamTTL - time.Second)
+	if _, err = proxy.Get(private.ID); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected an expired stream to be not found, got %v", err)
+	}
+	proxy.Client = files.Client()
+
+	for _, prefix := range []string{"/ranges", "/whole"} {
+		job := proxy.Add(Job{
+			Title:     "Movie",
+			URL:       files.URL + prefix + "/movie.mkv",
+			DetailURL: "https://engine.example/movie",
+			Headers:   map[string]string{"Cookie": "token=1"},
+		})
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/"+job.ID, nil)
+		req.Header.Set("Range", "bytes=10-19")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != http.StatusPartialContent || string(body) != string(content[10:20]) {
+			t.Errorf("%s: expected bytes 10 to 19, got %s %q", prefix, res.Status, body)
+		}
+		if got := res.Header.Get("Content-Range"); got != fmt.Sprintf("bytes 10-19/%d", len(content)) {
+			t.Errorf("%s: unexpected content range %q", prefix, got)
+		}
+		if got := res.Header.Get("Content-Type"); got != "video/x-matroska" {
+			t.Errorf("%s: expected the content type of the file, got %q", prefix, got)
+		}
+	}
+
+	job := proxy.Add(Job{Title: "Movie", URL: files.URL + "/ranges/movie.mkv"})
+	if len(proxy.jobs) != 3 {
+		t.Errorf("Expected the expired stream to be removed, got %d streams", len(proxy.jobs))
+	}
+	res, err = http.Get(ts.URL + "/" + job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected a link refused without its headers to fail, got %s", res.Status)
+	}
+	if res, err = http.Get(ts.URL + "/unknown"); err == nil {
+		res.Body.Close()
+	}
+	if err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected an unknown stream to be not found, got %v %v", res.Status, err)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	for _, test := range []struct{ link, disposition, name, expected string }{
+		{"https://example.com/files/movie.mp4", "", "Movie", "movie.mp4"},
+		{"https://example.com/download", `attachment; filename="movie.mkv"`, "Movie", "movie.mkv"},
+		{"https://example.com/download", `attachment; filename=".."`, "Movie", "Movie"},
+		{"https://example.com/files/..", "", "Movie", "Movie"},
+		{"https://example.com/files/..%2F..%2Fmovie.mp4", "", "Movie", "movie.mp4"},
+		{"https://example.com/download", "", "..", "Untitled"},
+	} {
+		u, _ := url.Parse(test.link)
+		resp := &http.Response{Header: http.Header{}, Request: &http.Request{URL: u}}
+		if test.disposition != "" {
+			resp.Header.Set("Content-Disposition", test.disposition)
+		}
+		if got := fileName(resp, test.name); got != test.expected {
+			t.Errorf("%s %s: expected %q, got %q", test.link, test.disposition, test.expected, got)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	for value, expected := range map[string][3]int64{
+		"bytes=0-9":     {0, 9, 1},
+		"bytes=90-":     {90, 99, 1},
+		"bytes=-10":     {90, 99, 1},
+		"bytes=95-200":  {95, 99, 1},
+		"bytes=100-":    {0, 0, 0},
+		"bytes=0-1,5-6": {0, 0, 0},
+		"items=0-9":     {0, 0, 0},
+	} {
+		start, end, ok := parseRange(value, 100)
+		if got := [3]int64{start, end, map[bool]int64{true: 1}[ok]}; got != expected {
+			t.Errorf("%s: expected %v, got %v", value, expected, got)
+		}
+	}
+}
+
+func TestStreamWhileDownloading(t *testing.T) {
+	content := make([]byte, 4*6<<20)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	seek := int64(5 << 20)
+	// The segments the file is split in at first wait for the gate, so that only the part the
+	// player seeks to is downloaded until then
+	gate := make(chan struct{})
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start int64
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start)
+		if start != seek && r.Header.Get("Range") != "bytes=0-0" {
+			select {
+			case <-gate:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		http.ServeContent(w, r, "movie.mp4", time.Time{}, bytes.NewReader(content))
+	}))
+	defer files.Close()
+	manager := NewManager(NewStore(filepath.Join(t.TempDir(), "downloads.json")), 1)
+	job, err := manager.Add(Job{Title: "Movie", URL: files.URL + "/movie.mp4", Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	downloaded := make(chan error, 1)
+	go func() {
+		_, err := manager.DownloadQueued(ctx, job.ID)
+		downloaded <- err
+	}()
+	if job, err = manager.WaitStarted(ctx, job.ID); err != nil || job.State != StateRunning {
+		t.Fatalf("Expected the download to start, got %s %v", job.State, err)
+	}
+
+	proxy := NewProxy(manager)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxy.ServeJob(w, r, job.ID)
+	}))
+	defer ts.Close()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", seek, seek+99))
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil || res.StatusCode != http.StatusPartialContent || !bytes.Equal(body, content[seek:seek+100]) {
+		t.Fatalf("Expected the part sought to be streamed before the rest is downloaded, got %s %v", res.Status, err)
+	}
+
+	close(gate)
+	if err = <-downloaded; err != nil {
+		t.Fatal(err)
+	}
+	if job, err = manager.Store().Get(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadFile(job.File); !bytes.Equal(got, content) {
+		t.Errorf("Expected the whole file to be downloaded after streaming part of it")
+	}
+}
+
+func TestPartialFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "movie.mp4")
+	content := []byte("the whole movie")
+	writeState := func(done int64) {
+		state, _ := json.Marshal(partState{Size: int64(len(content)), Segments: []*segment{{Start: 0, End: int64(len(content)) - 1, Done: done}}})
+		if err := ioutil.WriteFile(file+".part.json", state, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(file+".part", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeState(9)
+	partial, err := OpenPartial(context.Background(), Job{File: file, Size: int64(len(content))}, func(offset int64) error {
+		writeState(int64(len(content)))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer partial.Close()
+
+	b := make([]byte, 4)
+	if n, _ := partial.Read(b); string(b[:n]) != "the " {
+		t.Errorf("Expected the start of the file, got %q", b[:n])
+	}
+	checked := partial.checked
+	if n, _ := partial.Read(b); string(b[:n]) != "whol" || partial.checked != checked {
+		t.Errorf("Expected a downloaded part to be read without checking the disk, got %q", b[:n])
+	}
+	// The rest is downloaded while the read waits, and renamed once complete
+	if err = os.Rename(file+".part", file); err != nil {
+		t.Fatal(err)
+	}
+	if rest, err := ioutil.ReadAll(partial); string(rest) != "e movie" || err != nil {
+		t.Errorf("Expected the rest of the file, got %q and %v", rest, err)
+	}
 }
 
-// Filesize must be greater than 0
-func TestFileSize(t *testing.T) {
-	f.DownloadFile()
+func TestSplitSegment(t *testing.T) {
+	state := &partState{Segments: []*segment{
+		{Start: 0, End: 10<<20 - 1, Done: 1 << 20},
+		{Start: 10 << 20, End: 20<<20 - 1},
+	}}
+	if s := state.split(2 << 20); s != nil {
+		t.Errorf("Expected an offset about to be downloaded not to split its segment, got %+v", s)
+	}
+	if s := state.split(512 << 10); s != nil {
+		t.Errorf("Expected a downloaded offset not to split its segment, got %+v", s)
+	}
+	s := state.split(6 << 20)
+	if s == nil || s.Start != 6<<20 || s.End != 10<<20-1 || state.Segments[0].End != 6<<20-1 || len(state.Segments) != 3 {
+		t.Errorf("Expected the first segment to be split at 6MiB, got %+v", state.Segments)
+	}
 }
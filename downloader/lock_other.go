@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package downloader
+
+import "os"
+
+// lockFile : file locks are not supported, the store is only safe within a single process
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile : file locks are not supported
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package downloader
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile : block until an exclusive lock is held on f
+func lockFile(f *os.File) error {
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &windows.Overlapped{})
+}
+
+// unlockFile : release the lock held on f
+func unlockFile(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &windows.Overlapped{})
+}
@@ -0,0 +1,815 @@
+package downloader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-phie/gophie/engine"
+	log "github.com/sirupsen/logrus"
+)
+
+// State : the state of a download job
+type State string
+
+// States of a download job
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StatePaused    State = "paused"
+	StateFailed    State = "failed"
+	StateCompleted State = "completed"
+)
+
+// How often idle workers look for jobs queued by other processes
+const pollInterval = 5 * time.Second
+
+// How often running jobs are marked as alive in the store, and how long a running job that was
+// not marked is thought to be left by a process that stopped
+const (
+	heartbeatInterval = 10 * time.Second
+	heartbeatTimeout  = 3 * heartbeatInterval
+)
+
+// Job : a file to download and the state of its download
+type Job struct {
+	ID        string
+	Title     string
+	URL       string // URL Source
+	Dir       string // Directory to store the file
+	Source    string // Name of the engine the movie is from
+	Engine    string `json:",omitempty"` // Registered name of the engine, used to refresh expired links
+	DetailURL string `json:",omitempty"` // Page of the movie on the engine the URL was resolved from
+	Season    int    `json:",omitempty"` // Season of the episode if the job is an episode of a series
+	Episode   int    `json:",omitempty"` // Number of the episode if the job is an episode of a series
+	BaseName  string `json:",omitempty"` // Name of the file without extension, the name given by the server if empty
+	Variant   string `json:",omitempty"` // Quality of the file when the movie is available in several
+	Size      int64  // Size of the file if known
+	File      string // Path of the downloaded file once it is known
+	// Headers sent with every request for the file, e.g the Referer or Cookie a file host expects
+	Headers map[string]string `json:",omitempty"`
+	// Subtitle of the file, downloaded next to it when Subtitles is set
+	SubtitleURL  string `json:",omitempty"`
+	Subtitles    bool   `json:",omitempty"`
+	SubtitleFile string `json:",omitempty"`
+	State        State
+	Error        string `json:",omitempty"` // Why the download failed
+	CreatedAt    time.Time
+	UpdatedAt    time.Time // Refreshed while the job is running, so that other processes leave it alone
+	// Called with the progress of the download, set by the manager running the job
+	OnProgress func(downloaded, size int64) `json:"-"`
+	// Called with the file and its size once the download starts, set by the manager running the job
+	OnStart func(file string, size int64) `json:"-"`
+	// Offsets of the file to download first, set by the manager running the job
+	Priority <-chan int64 `json:"-"`
+}
+
+// NewJob : a job to download movie to a folder named after it in outputDir
+func NewJob(movie *engine.Movie, outputDir string) Job {
+	job := Job{
+		Title:  movie.Title,
+		URL:    movie.DownloadLink.String(),
+		Dir:    titleDir(outputDir, movie.Title),
+		Source: movie.Source,
+		Engine: strings.ToLower(movie.Source),
+	}
+	if movie.DetailLink != nil {
+		job.DetailURL = movie.DetailLink.String()
+	}
+	if movie.SubtitleLink != nil {
+		job.SubtitleURL = movie.SubtitleLink.String()
+	}
+	job.Variant = variantOf(movie.Variants, job.URL)
+	return job
+}
+
+// NewEpisodeJob : a job to download an episode of series to a folder named after the series in
+// outputDir, as a file named after the series and the episode e.g `Show S01E03.mkv`
+func NewEpisodeJob(series *engine.Movie, episode engine.Episode, outputDir string) Job {
+	name := fmt.Sprintf("%s %s", strings.TrimSpace(series.Title), episode.Code())
+	job := Job{
+		Title:    name,
+		URL:      episode.Link.String(),
+		Dir:      titleDir(outputDir, series.Title),
+		Source:   series.Source,
+		Engine:   strings.ToLower(series.Source),
+		Season:   episode.Season,
+		Episode:  episode.Number,
+		BaseName: separators.Replace(name),
+	}
+	if series.DetailLink != nil {
+		job.DetailURL = series.DetailLink.String()
+	}
+	if episode.Subtitle != nil {
+		job.SubtitleURL = episode.Subtitle.String()
+	}
+	job.Variant = variantOf(episode.Variants, job.URL)
+	return job
+}
+
+// Replaces the path separators in titles, which are used as names of files and folders
+var separators = strings.NewReplacer("/", "-", "\\", "-")
+
+// titleDir : the folder named after title in outputDir
+// Titles come from the pages of engines, so they are made a single path element that stays in outputDir
+func titleDir(outputDir, title string) string {
+	name := separators.Replace(title)
+	if strings.Trim(name, ". ") == "" {
+		name = "Untitled"
+	}
+	dir := filepath.Join(outputDir, name)
+	if rel, err := filepath.Rel(outputDir, dir); err != nil || rel != name {
+		return filepath.Join(outputDir, "Untitled")
+	}
+	return dir
+}
+
+// variantOf : the quality of the variant at link, empty if link is not one of several variants
+func variantOf(variants []engine.Variant, link string) string {
+	if len(variants) < 2 {
+		return ""
+	}
+	for _, variant := range variants {
+		if variant.Link != nil && variant.Link.String() == link {
+			return variant.Quality
+		}
+	}
+	return ""
+}
+
+// linkOf : the link of the variant of quality, or link if there is none
+func linkOf(variants []engine.Variant, quality string, link *url.URL) *url.URL {
+	for _, variant := range variants {
+		if quality != "" && variant.Quality == quality && variant.Link != nil {
+			return variant.Link
+		}
+	}
+	return link
+}
+
+// RefreshURL : resolve the download link of the job again from its detail page on its engine
+// Episodes of series are found by their title among the links of the series
+func (j *Job) RefreshURL(ctx context.Context) error {
+	if j.Engine == "" || j.DetailURL == "" {
+		return errors.New("the engine and page the link was resolved from are unknown")
+	}
+	e, err := engine.GetEngine(j.Engine)
+	if err != nil {
+		return err
+	}
+	detailLink, err := url.Parse(j.DetailURL)
+	if err != nil {
+		return err
+	}
+	movie, err := engine.ResolveDownloadLink(ctx, e, engine.Movie{Title: j.Title, DetailLink: detailLink})
+	if err != nil {
+		return err
+	}
+	link := linkOf(movie.Variants, j.Variant, movie.DownloadLink)
+	if episodeLink, ok := movie.SDownloadLink[j.Title]; ok {
+		link = episodeLink
+	}
+	if j.Episode > 0 {
+		link = nil
+		for _, episode := range movie.Episodes() {
+			if episode.Season == j.Season && episode.Number == j.Episode {
+				link = linkOf(episode.Variants, j.Variant, episode.Link)
+			}
+		}
+	}
+	if link == nil || link.String() == j.DetailURL {
+		return fmt.Errorf("no download link found on %s", j.DetailURL)
+	}
+	log.Debugf("Refreshed download link of %s to %v", j.Title, link)
+	j.URL = link.String()
+	return nil
+}
+
+// Downloader : the downloader of the file of the job
+func (j *Job) Downloader() *Downloader {
+	d := &Downloader{
+		URL:        j.URL,
+		Dir:        j.Dir,
+		Name:       j.Title,
+		BaseName:   j.BaseName,
+		Source:     j.Source,
+		Size:       j.Size,
+		Headers:    j.Headers,
+		OnProgress: j.OnProgress,
+		OnStart:    j.OnStart,
+		Priority:   j.Priority,
+	}
+	if j.File != "" {
+		// Continue with the file of previous attempts
+		d.FileName = filepath.Base(j.File)
+	}
+	return d
+}
+
+// Completed : reports whether the job finished and its file is still on disk with the expected size
+func (j *Job) Completed() bool {
+	if j.State != StateCompleted || j.File == "" {
+		return false
+	}
+	info, err := os.Stat(j.File)
+	return err == nil && (j.Size <= 0 || info.Size() == j.Size)
+}
+
+// Downloaded : the number of bytes of the file on disk, from the partial download if it is not complete
+func (j *Job) Downloaded() int64 {
+	if j.File == "" {
+		return 0
+	}
+	if info, err := os.Stat(j.File); err == nil {
+		return info.Size()
+	}
+	// Partial files are allocated to their full size, so count the progress of their segments
+	content, err := ioutil.ReadFile(j.File + ".part.json")
+	if err != nil {
+		return 0
+	}
+	var state partState
+	if err = json.Unmarshal(content, &state); err != nil {
+		return 0
+	}
+	var done int64
+	for _, s := range state.Segments {
+		done += s.Done
+	}
+	return done
+}
+
+// RemoveFiles : delete the downloaded file of the job and any partial download
+func (j *Job) RemoveFiles() error {
+	if j.File == "" {
+		return nil
+	}
+	files := []string{j.File, j.File + ".part", j.File + ".part.json"}
+	if j.SubtitleFile != "" {
+		files = append(files, j.SubtitleFile)
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Runner : downloads the file of a job, returning early with an error once ctx is done
+// Runners may update the Size and File of the job
+type Runner func(ctx context.Context, job *Job) error
+
+// DownloadJob : the default Runner, download the file of job with a Downloader
+func DownloadJob(ctx context.Context, job *Job) error {
+	return runDownloader(ctx, job, job.Downloader())
+}
+
+// runDownloader : download the file of job with d and update the job from it
+// An expired link is resolved again and the download continues with the fresh link
+func runDownloader(ctx context.Context, job *Job, d *Downloader) error {
+	err := d.DownloadFile(ctx)
+	if errors.Is(err, ErrLinkExpired) && ctx.Err() == nil {
+		log.Infof("Download link of %s expired, resolving it again", job.Title)
+		if refreshErr := job.RefreshURL(ctx); refreshErr != nil {
+			return fmt.Errorf("%w, and it could not be refreshed: %v", err, refreshErr)
+		}
+		d.URL = job.URL
+		err = d.DownloadFile(ctx)
+	}
+	job.Size = d.Size
+	if d.FileName != "" {
+		job.File = d.Path()
+	}
+	if err == nil {
+		downloadJobSubtitle(ctx, job, d.client())
+	}
+	return err
+}
+
+// runningJob : a job being downloaded by this manager
+type runningJob struct {
+	cancel   context.CancelFunc
+	paused   bool       // Cancelled to pause the job rather than to stop the manager
+	priority chan int64 // Offsets of the file to download first
+}
+
+// Manager : downloads the jobs queued in a Store with a limited number of parallel downloads
+// Several managers, even in different processes, can share a store without downloading a job twice
+type Manager struct {
+	Runner Runner // Downloads the file of a job, must be set before the manager is used
+
+	store       *Store
+	parallel    int
+	mu          sync.Mutex
+	running     map[string]*runningJob
+	subscribers map[chan Progress]struct{}
+	progress    map[string]Progress // Latest progress of the jobs running in this manager
+	wake        chan struct{}
+}
+
+// NewManager : A Download Manager Constructor for jobs in store with up to parallel downloads at a time
+func NewManager(store *Store, parallel int) *Manager {
+	if parallel < 1 {
+		parallel = 1
+	}
+	return &Manager{
+		Runner:      DownloadJob,
+		store:       store,
+		parallel:    parallel,
+		running:     make(map[string]*runningJob),
+		subscribers: make(map[chan Progress]struct{}),
+		progress:    make(map[string]Progress),
+		wake:        make(chan struct{}, parallel),
+	}
+}
+
+// Subscribe : receive the progress of the jobs downloaded or changed by the manager until cancel
+// is called. Events are dropped while the channel is full so that a slow subscriber never holds
+// up downloads
+func (m *Manager) Subscribe() (events <-chan Progress, cancel func()) {
+	ch := make(chan Progress, 64)
+	m.mu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.subscribers, ch)
+			m.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Progress : the progress of job, with its speed and time left if this manager is downloading it
+func (m *Manager) Progress(job Job) Progress {
+	m.mu.Lock()
+	p, ok := m.progress[job.ID]
+	m.mu.Unlock()
+	if ok {
+		return p
+	}
+	return job.Progress()
+}
+
+// Prioritize : download the file of the job with id from offset first, e.g where a player streaming
+// it seeks to. It reports whether the job is running in this manager, and so whether it is heeded
+func (m *Manager) Prioritize(id string, offset int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.running[id]
+	if !ok {
+		return false
+	}
+	select {
+	case r.priority <- offset:
+	default:
+		// Only the latest offset matters to a player that seeks again
+		select {
+		case <-r.priority:
+		default:
+		}
+		r.priority <- offset
+	}
+	return true
+}
+
+// publish : send p to every subscriber
+func (m *Manager) publish(p Progress) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p.State == StateRunning {
+		m.progress[p.JobID] = p
+	} else {
+		delete(m.progress, p.JobID)
+	}
+	for ch := range m.subscribers {
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// Store : the store of the jobs of the manager
+func (m *Manager) Store() *Store {
+	return m.store
+}
+
+// newJobID : a random id for a job
+func newJobID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// notify : wake an idle worker to pick up a queued job
+func (m *Manager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// active : reports whether job is queued, or running in a process that is still alive
+func (j *Job) active() bool {
+	return j.State == StateQueued || (j.State == StateRunning && time.Since(j.UpdatedAt) < heartbeatTimeout)
+}
+
+// busy : reports whether job is queued, or running in this manager or another live process
+func (m *Manager) busy(job *Job) bool {
+	m.mu.Lock()
+	_, running := m.running[job.ID]
+	m.mu.Unlock()
+	return running || job.active()
+}
+
+// Add : queue job for download. A job that is already queued or running is returned as it is stored,
+// and a job queued again keeps the time it was first added
+func (m *Manager) Add(job Job) (Job, error) {
+	if job.ID == "" {
+		job.ID = newJobID()
+	}
+	added := true
+	err := m.store.Update(func(jobs map[string]*Job) error {
+		if stored, ok := jobs[job.ID]; ok {
+			if m.busy(stored) {
+				job, added = *stored, false
+				return nil
+			}
+			job.CreatedAt = stored.CreatedAt
+		}
+		job.State = StateQueued
+		job.Error = ""
+		job.UpdatedAt = time.Now()
+		if job.CreatedAt.IsZero() {
+			job.CreatedAt = job.UpdatedAt
+		}
+		queued := job
+		jobs[job.ID] = &queued
+		return nil
+	})
+	if err != nil || !added {
+		return job, err
+	}
+	m.publish(job.Progress())
+	m.notify()
+	return job, nil
+}
+
+// Pause : stop downloading a job until it is resumed
+func (m *Manager) Pause(id string) error {
+	var paused *Job
+	err := m.store.Update(func(jobs map[string]*Job) error {
+		job, ok := jobs[id]
+		if !ok {
+			return ErrJobNotFound
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if r, ok := m.running[id]; ok {
+			// The worker marks the job as paused once the download stops
+			r.paused = true
+			r.cancel()
+			return nil
+		}
+		if job.State == StateRunning && job.active() {
+			return fmt.Errorf("%s is being downloaded by another process", job.Title)
+		}
+		switch job.State {
+		case StateQueued, StateRunning:
+			// A running job that is not running here was left by a process that stopped
+			job.State = StatePaused
+			job.UpdatedAt = time.Now()
+			paused = job
+		case StatePaused:
+		default:
+			return fmt.Errorf("cannot pause a %s download", job.State)
+		}
+		return nil
+	})
+	if err == nil && paused != nil {
+		m.publish(paused.Progress())
+	}
+	return err
+}
+
+// Resume : queue a paused or failed job again, or a completed job whose file is missing
+func (m *Manager) Resume(id string) error {
+	var resumed *Job
+	err := m.store.Update(func(jobs map[string]*Job) error {
+		job, ok := jobs[id]
+		if !ok {
+			return ErrJobNotFound
+		}
+		switch {
+		case m.busy(job):
+			// Jobs running in another process are left to it
+			return nil
+		case job.Completed():
+			return ErrAlreadyDownloaded
+		}
+		job.State = StateQueued
+		job.Error = ""
+		job.UpdatedAt = time.Now()
+		resumed = job
+		return nil
+	})
+	if err == nil && resumed != nil {
+		m.publish(resumed.Progress())
+		m.notify()
+	}
+	return err
+}
+
+// Remove : stop downloading a job and remove it from the store
+// Files already downloaded are left on disk
+func (m *Manager) Remove(id string) error {
+	return m.store.Update(func(jobs map[string]*Job) error {
+		if _, ok := jobs[id]; !ok {
+			return ErrJobNotFound
+		}
+		m.mu.Lock()
+		if r, ok := m.running[id]; ok {
+			r.cancel()
+		}
+		m.mu.Unlock()
+		delete(jobs, id)
+		return nil
+	})
+}
+
+// claim : mark the job with id, or the oldest queued job if id is empty, as running in this manager
+// It returns a nil job if there is no job to claim
+func (m *Manager) claim(ctx context.Context, id string) (*Job, context.Context, error) {
+	var (
+		claimed *Job
+		jobCtx  context.Context
+	)
+	err := m.store.Update(func(jobs map[string]*Job) error {
+		if id != "" {
+			if job, ok := jobs[id]; ok && job.State == StateQueued {
+				claimed = job
+			}
+		} else {
+			for _, job := range sortJobs(jobs) {
+				if job.State == StateQueued {
+					claimed = job
+					break
+				}
+			}
+		}
+		if claimed == nil {
+			return nil
+		}
+		claimed.State = StateRunning
+		claimed.UpdatedAt = time.Now()
+		var cancel context.CancelFunc
+		jobCtx, cancel = context.WithCancel(ctx)
+		m.mu.Lock()
+		m.running[claimed.ID] = &runningJob{cancel: cancel, priority: make(chan int64, 1)}
+		m.mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		if claimed != nil {
+			m.release(claimed.ID)
+		}
+		return nil, nil, err
+	}
+	if claimed == nil {
+		return nil, nil, nil
+	}
+	job := *claimed
+	return &job, jobCtx, nil
+}
+
+// release : forget a job running in this manager
+func (m *Manager) release(id string) *runningJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.running[id]
+	if !ok {
+		return nil
+	}
+	delete(m.running, id)
+	r.cancel()
+	return r
+}
+
+// execute : download a claimed job and save its final state
+func (m *Manager) execute(ctx, jobCtx context.Context, job *Job) {
+	log.Debugf("Downloading %s", job.Title)
+	m.publish(job.Progress())
+	meter := &progressMeter{}
+	job.OnProgress = func(downloaded, size int64) {
+		p := Progress{JobID: job.ID, Title: job.Title, State: StateRunning, Downloaded: downloaded, Size: size, Time: time.Now()}
+		p.Speed, p.ETA = meter.update(downloaded, size)
+		m.publish(p)
+	}
+	// The file is known before it is downloaded, so that it can be streamed while it is
+	job.OnStart = func(file string, size int64) {
+		err := m.store.Update(func(jobs map[string]*Job) error {
+			if stored, ok := jobs[job.ID]; ok {
+				stored.File, stored.Size = file, size
+			}
+			return nil
+		})
+		if err != nil {
+			log.Debugf("Could not save the file of %s: %v", job.Title, err)
+		}
+	}
+	m.mu.Lock()
+	if r, ok := m.running[job.ID]; ok {
+		job.Priority = r.priority
+	}
+	m.mu.Unlock()
+	stopHeartbeat := m.heartbeat(job.ID)
+	runErr := m.Runner(jobCtx, job)
+	stopHeartbeat()
+	var final *Job
+	err := m.store.Update(func(jobs map[string]*Job) error {
+		r := m.release(job.ID)
+		stored, ok := jobs[job.ID]
+		if !ok {
+			// Removed while downloading
+			return nil
+		}
+		if job.Size > 0 {
+			stored.Size = job.Size
+		}
+		if job.File != "" {
+			stored.File = job.File
+		}
+		if job.SubtitleFile != "" {
+			stored.SubtitleFile = job.SubtitleFile
+		}
+		// The link may have been refreshed
+		stored.URL = job.URL
+		stored.Error = ""
+		switch {
+		case runErr == nil:
+			stored.State = StateCompleted
+		case r != nil && r.paused:
+			stored.State = StatePaused
+		case ctx.Err() != nil:
+			// The manager was stopped, the job is picked up again by the next manager
+			stored.State = StateQueued
+		default:
+			stored.State = StateFailed
+			stored.Error = runErr.Error()
+			log.Errorf("Download of %s failed: %v", job.Title, runErr)
+		}
+		stored.UpdatedAt = time.Now()
+		final = stored
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Could not save the state of %s: %v", job.Title, err)
+	}
+	if final != nil {
+		m.publish(final.Progress())
+	}
+}
+
+// heartbeat : mark the job with id as alive in the store until the returned function is called,
+// so that other processes do not take it over
+func (m *Manager) heartbeat(id string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			err := m.store.Update(func(jobs map[string]*Job) error {
+				if stored, ok := jobs[id]; ok && stored.State == StateRunning {
+					stored.UpdatedAt = time.Now()
+				}
+				return nil
+			})
+			if err != nil {
+				log.Debugf("Could not mark %s as running: %v", id, err)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// work : download queued jobs with up to parallel workers
+// Workers return once no job is queued unless wait is set, in which case they wait for new jobs
+func (m *Manager) work(ctx context.Context, wait bool) {
+	var wg sync.WaitGroup
+	for i := 0; i < m.parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				job, jobCtx, err := m.claim(ctx, "")
+				if err != nil {
+					log.Error(err)
+				}
+				if job != nil {
+					m.execute(ctx, jobCtx, job)
+					continue
+				}
+				if !wait {
+					return
+				}
+				select {
+				case <-ctx.Done():
+				case <-m.wake:
+				case <-time.After(pollInterval):
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Run : download the queued jobs and return once none are left or ctx is done
+// Jobs interrupted by ctx are queued again
+func (m *Manager) Run(ctx context.Context) {
+	m.work(ctx, false)
+}
+
+// Serve : download queued jobs, waiting for new jobs until ctx is done
+func (m *Manager) Serve(ctx context.Context) {
+	m.work(ctx, true)
+}
+
+// Download : add job to the queue and download it right away, returning once it is done
+func (m *Manager) Download(ctx context.Context, job Job) (Job, error) {
+	job, err := m.Add(job)
+	if err != nil {
+		return job, err
+	}
+	return m.DownloadQueued(ctx, job.ID)
+}
+
+// DownloadQueued : download the queued job with id right away, returning once it is done
+func (m *Manager) DownloadQueued(ctx context.Context, id string) (Job, error) {
+	job, err := m.store.Get(id)
+	if err != nil {
+		return job, err
+	}
+	claimed, jobCtx, err := m.claim(ctx, id)
+	if err != nil {
+		return job, err
+	}
+	if claimed == nil {
+		return job, fmt.Errorf("%s is being downloaded by another process", job.Title)
+	}
+	m.execute(ctx, jobCtx, claimed)
+	if job, err = m.store.Get(id); err != nil {
+		return job, err
+	}
+	switch job.State {
+	case StateCompleted:
+		return job, nil
+	case StateFailed:
+		return job, errors.New(job.Error)
+	}
+	if err = ctx.Err(); err != nil {
+		return job, err
+	}
+	return job, fmt.Errorf("download is %s", job.State)
+}
+
+// WaitStarted : the job with id once it is running and its file is known, polling the store until
+// its download starts or stops. A job that is neither queued nor running is returned as it is
+func (m *Manager) WaitStarted(ctx context.Context, id string) (Job, error) {
+	for {
+		job, err := m.store.Get(id)
+		if err != nil || (job.State == StateRunning && job.File != "") || (job.State != StateQueued && job.State != StateRunning) {
+			return job, err
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-time.After(partialPoll):
+		}
+	}
+}
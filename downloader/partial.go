@@ -0,0 +1,146 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// How often a partial file is checked for the bytes a read is waiting for
+var partialPoll = 200 * time.Millisecond
+
+// PartialFile : the file of a job read while it is downloaded
+// Reads wait for the bytes they need to reach the disk, which are first asked for with missing.
+// The downloaded parts of the file are only read again from disk once a read goes past them, at
+// most once every partialPoll
+type PartialFile struct {
+	ctx      context.Context
+	file     string // Path of the file once it is downloaded
+	size     int64
+	offset   int64
+	missing  func(offset int64) error
+	f        *os.File   // The partial file, or the downloaded file once complete
+	complete bool       // Whether f is the downloaded file
+	segments []*segment // Parts of the partial file on disk when they were last read
+	checked  time.Time  // Last time the parts on disk were read
+}
+
+// OpenPartial : read the file of job, downloaded or not, until ctx is done
+// missing is called while a read waits for the bytes at offset, and stops the read with its error
+func OpenPartial(ctx context.Context, job Job, missing func(offset int64) error) (*PartialFile, error) {
+	if job.File == "" || job.Size <= 0 {
+		return nil, fmt.Errorf("the file and size of %s are not known yet", job.Title)
+	}
+	return &PartialFile{ctx: ctx, file: job.File, size: job.Size, missing: missing}, nil
+}
+
+// Seek : move the offset of the next read
+func (p *PartialFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += p.offset
+	case io.SeekEnd:
+		offset += p.size
+	}
+	if offset < 0 {
+		return p.offset, errors.New("seek before the start of the file")
+	}
+	p.offset = offset
+	return offset, nil
+}
+
+// Read : read from the offset once the bytes there are downloaded
+func (p *PartialFile) Read(b []byte) (int, error) {
+	if p.offset >= p.size {
+		return 0, io.EOF
+	}
+	for {
+		available := p.available(p.offset)
+		if available == 0 && time.Since(p.checked) >= partialPoll {
+			if err := p.refresh(); err != nil {
+				return 0, err
+			}
+			available = p.available(p.offset)
+		}
+		if available > 0 {
+			if int64(len(b)) > available {
+				b = b[:available]
+			}
+			n, err := p.f.ReadAt(b, p.offset)
+			if err == io.EOF && n > 0 {
+				err = nil
+			}
+			p.offset += int64(n)
+			return n, err
+		}
+		if err := p.missing(p.offset); err != nil {
+			return 0, err
+		}
+		select {
+		case <-p.ctx.Done():
+			return 0, p.ctx.Err()
+		case <-time.After(partialPoll):
+		}
+	}
+}
+
+// Close : close the file being read
+func (p *PartialFile) Close() error {
+	if p.f == nil {
+		return nil
+	}
+	return p.f.Close()
+}
+
+// available : how many bytes follow offset on disk as of the last refresh, zero if the bytes at
+// offset were not downloaded yet
+func (p *PartialFile) available(offset int64) int64 {
+	if p.complete {
+		return p.size - offset
+	}
+	for _, s := range p.segments {
+		if offset >= s.Start && offset < s.Start+s.Done {
+			return s.Start + s.Done - offset
+		}
+	}
+	return 0
+}
+
+// refresh : read the parts of the file on disk again, switching to the downloaded file once the
+// download is complete. The partial file is kept open, so that it can still be read once renamed
+func (p *PartialFile) refresh() error {
+	p.checked = time.Now()
+	if info, err := os.Stat(p.file); err == nil && info.Size() == p.size {
+		f, err := os.Open(p.file)
+		if err != nil {
+			return err
+		}
+		p.Close()
+		p.f, p.complete, p.segments = f, true, nil
+		return nil
+	}
+	content, err := ioutil.ReadFile(p.file + ".part.json")
+	if err != nil {
+		// The download has not saved its progress yet
+		return nil
+	}
+	var state partState
+	if err = json.Unmarshal(content, &state); err != nil {
+		return nil
+	}
+	if p.f == nil {
+		if p.f, err = os.Open(p.file + ".part"); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+	}
+	p.segments = state.Segments
+	return nil
+}
@@ -0,0 +1,186 @@
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress : an event of the progress of a download job, sent when its state changes and
+// regularly while it is downloaded
+type Progress struct {
+	JobID      string
+	Title      string
+	State      State
+	Downloaded int64         // Bytes downloaded
+	Size       int64         // Size of the file, zero if unknown
+	Speed      int64         // Bytes downloaded per second, zero unless the job is running
+	ETA        time.Duration // Time left to download the file, zero if unknown
+	Error      string        `json:",omitempty"` // Why the download failed
+	Time       time.Time
+}
+
+// ProgressJSON : JSON structure of a progress event, with the time left in seconds
+type ProgressJSON struct {
+	Progress
+	Percent float64 `json:",omitempty"`
+	ETA     int64   `json:",omitempty"`
+}
+
+// MarshalJSON Json structure to return from api
+func (p *Progress) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ProgressJSON{
+		Progress: *p,
+		Percent:  p.Percent(),
+		ETA:      int64(p.ETA.Seconds()),
+	})
+}
+
+// Percent : the percentage of the file downloaded, zero if its size is unknown
+func (p Progress) Percent() float64 {
+	if p.Size <= 0 {
+		return 0
+	}
+	return float64(p.Downloaded) * 100 / float64(p.Size)
+}
+
+// Progress : the progress of the job as last stored, without its speed
+func (j *Job) Progress() Progress {
+	return Progress{
+		JobID:      j.ID,
+		Title:      j.Title,
+		State:      j.State,
+		Downloaded: j.Downloaded(),
+		Size:       j.Size,
+		Error:      j.Error,
+		Time:       time.Now(),
+	}
+}
+
+// progressMeter : the speed and time left of a download from its successive progress
+type progressMeter struct {
+	last       time.Time
+	downloaded int64
+	speed      float64
+}
+
+// update : the speed and time left of the download once downloaded bytes out of size are done
+// The speed is smoothed over the previous updates so that it does not jump around
+func (m *progressMeter) update(downloaded, size int64) (int64, time.Duration) {
+	now := time.Now()
+	if !m.last.IsZero() {
+		if elapsed := now.Sub(m.last).Seconds(); elapsed > 0 {
+			current := float64(downloaded-m.downloaded) / elapsed
+			if current < 0 {
+				current = 0
+			}
+			if m.speed == 0 {
+				m.speed = current
+			} else {
+				m.speed = 0.7*m.speed + 0.3*current
+			}
+		}
+	}
+	m.last, m.downloaded = now, downloaded
+	var eta time.Duration
+	if m.speed > 0 && size > downloaded {
+		eta = time.Duration(float64(size-downloaded) / m.speed * float64(time.Second))
+	}
+	return int64(m.speed), eta
+}
+
+// ProgressBar : renders the progress of download jobs as a line each, redrawn as they progress
+// Only changes of state are written when the output is not a terminal
+type ProgressBar struct {
+	out      io.Writer
+	terminal bool
+	mu       sync.Mutex
+	order    []string // Ids of the jobs in the order they were first seen
+	jobs     map[string]Progress
+	drawn    int // Lines drawn by the last render
+}
+
+// NewProgressBar : a progress bar writing to out
+func NewProgressBar(out io.Writer) *ProgressBar {
+	bar := &ProgressBar{out: out, jobs: make(map[string]Progress)}
+	if f, ok := out.(*os.File); ok {
+		if info, err := f.Stat(); err == nil {
+			bar.terminal = info.Mode()&os.ModeCharDevice != 0
+		}
+	}
+	return bar
+}
+
+// Render : show p, the latest progress of its job
+func (b *ProgressBar) Render(p Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	previous, seen := b.jobs[p.JobID]
+	if !seen {
+		b.order = append(b.order, p.JobID)
+	}
+	b.jobs[p.JobID] = p
+	if !b.terminal {
+		if !seen || previous.State != p.State {
+			fmt.Fprintln(b.out, progressLine(p))
+		}
+		return
+	}
+	if b.drawn > 0 {
+		// Back to the first line drawn
+		fmt.Fprintf(b.out, "\r\033[%dA", b.drawn)
+	}
+	for _, id := range b.order {
+		fmt.Fprintf(b.out, "\r\033[K%s\n", progressLine(b.jobs[id]))
+	}
+	b.drawn = len(b.order)
+}
+
+// Follow : render the progress from events until the channel is closed
+func (b *ProgressBar) Follow(events <-chan Progress) {
+	for p := range events {
+		b.Render(p)
+	}
+}
+
+// progressLine : the progress of a job on a single line
+// e.g `Jumanji  [#########-----------]  45.2%  300.0 MiB / 663.6 MiB  2.1 MiB/s  ETA 3m12s`
+func progressLine(p Progress) string {
+	title := p.Title
+	if runes := []rune(title); len(runes) > 30 {
+		title = string(runes[:29]) + "…"
+	}
+	switch p.State {
+	case StateCompleted:
+		return fmt.Sprintf("%-30s  completed  %s", title, FormatBytes(p.Downloaded))
+	case StateFailed:
+		return fmt.Sprintf("%-30s  failed: %s", title, p.Error)
+	case StateQueued, StatePaused:
+		return fmt.Sprintf("%-30s  %s  %s", title, p.State, FormatBytes(p.Downloaded))
+	}
+	parts := []string{fmt.Sprintf("%-30s", title)}
+	if p.Size > 0 {
+		const width = 20
+		done := int(float64(width) * float64(p.Downloaded) / float64(p.Size))
+		if done > width {
+			done = width
+		}
+		parts = append(parts,
+			"["+strings.Repeat("#", done)+strings.Repeat("-", width-done)+"]",
+			fmt.Sprintf("%5.1f%%", p.Percent()),
+			fmt.Sprintf("%s / %s", FormatBytes(p.Downloaded), FormatBytes(p.Size)))
+	} else {
+		parts = append(parts, FormatBytes(p.Downloaded))
+	}
+	if p.Speed > 0 {
+		parts = append(parts, FormatBytes(p.Speed)+"/s")
+	}
+	if p.ETA > 0 {
+		parts = append(parts, "ETA "+p.ETA.Round(time.Second).String())
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,428 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Content types of video files, which are missing from the mime types of most systems
+var videoTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mkv":  "video/x-matroska",
+	".webm": "video/webm",
+	".avi":  "video/x-msvideo",
+	".mov":  "video/quicktime",
+	".ts":   "video/mp2t",
+	".flv":  "video/x-flv",
+	".3gp":  "video/3gpp",
+	".srt":  "application/x-subrip",
+}
+
+// ContentType : the content type of a file from the extension of its name, empty if unknown
+func ContentType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if contentType, ok := videoTypes[ext]; ok {
+		return contentType
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// ErrPrivateAddress : a proxy was asked for a file on a private network, which it does not serve
+var ErrPrivateAddress = errors.New("address is not public")
+
+// How long a job added to a proxy is served after it was last streamed
+const streamTTL = 6 * time.Hour
+
+// Networks that are not reachable from the internet, which may be reachable from the proxy
+var privateNetworks = parseNetworks(
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7",
+)
+
+// parseNetworks : the networks of cidrs
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// publicIP : reports whether ip is reachable from the internet
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialPublic : refuse connections to addresses that are not public, once their host is resolved
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// PublicLink : an error wrapping ErrPrivateAddress unless every address the host of link resolves to
+// is public, so that links given by API callers cannot reach the network gophie runs in
+func PublicLink(ctx context.Context, link *url.URL) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, link.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return fmt.Errorf("%w: %s", ErrPrivateAddress, link.Hostname())
+		}
+	}
+	return nil
+}
+
+// publicClient : the client proxies use by default. It only connects to public addresses, even
+// through redirects, so that links given to the proxy cannot reach the network it runs in
+var publicClient = func() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublic}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport}
+}()
+
+// proxyJob : a job added to a proxy and the last time it was streamed
+type proxyJob struct {
+	Job
+	used time.Time
+}
+
+// Proxy : serves the files of jobs from their download links, with the headers their file hosts
+// expect, or from disk once they are downloaded. Range requests are answered even when the file
+// host does not support them, so that players can seek through the file. Files being downloaded
+// are served from disk as they are downloaded
+type Proxy struct {
+	Client  *http.Client
+	manager *Manager
+	mu      sync.Mutex
+	jobs    map[string]*proxyJob // Jobs added to the proxy, until they are not streamed for streamTTL
+}
+
+// NewProxy : a proxy serving the jobs added to it and the jobs of the download queue of manager
+// The parts of files that players seek to are downloaded first by manager
+func NewProxy(manager *Manager) *Proxy {
+	return &Proxy{manager: manager, jobs: make(map[string]*proxyJob)}
+}
+
+// Add : serve the file of job, which is returned with the id it is served at
+func (p *Proxy) Add(job Job) Job {
+	if job.ID == "" {
+		job.ID = newJobID()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for id, added := range p.jobs {
+		if now.Sub(added.used) > streamTTL {
+			delete(p.jobs, id)
+		}
+	}
+	p.jobs[job.ID] = &proxyJob{Job: job, used: now}
+	return job
+}
+
+// Get : the job with id added to the proxy, or else in the download queue
+func (p *Proxy) Get(id string) (Job, error) {
+	job, _, err := p.lookup(id)
+	return job, err
+}
+
+// lookup : the job with id and whether it was added to the proxy rather than found in the queue
+func (p *Proxy) lookup(id string) (Job, bool, error) {
+	p.mu.Lock()
+	added, ok := p.jobs[id]
+	if ok && time.Since(added.used) <= streamTTL {
+		added.used = time.Now()
+		p.mu.Unlock()
+		return added.Job, true, nil
+	}
+	p.mu.Unlock()
+	if p.manager == nil {
+		return Job{}, false, ErrJobNotFound
+	}
+	job, err := p.manager.Store().Get(id)
+	return job, false, err
+}
+
+// refreshed : keep the refreshed link of job for the next requests. Jobs of the queue keep it in
+// the store, so that their download uses it too
+func (p *Proxy) refreshed(job Job, added bool) {
+	if added {
+		p.Add(job)
+		return
+	}
+	err := p.manager.Store().Update(func(jobs map[string]*Job) error {
+		if stored, ok := jobs[job.ID]; ok {
+			stored.URL = job.URL
+		}
+		return nil
+	})
+	if err != nil {
+		log.Debugf("Could not save the refreshed link of %s: %v", job.Title, err)
+	}
+}
+
+// client : the client files are requested with, which only reaches public addresses unless set
+func (p *Proxy) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return publicClient
+}
+
+// ServeJob : respond to r with the file of the job with id, or the part of it asked by r
+// An expired link is resolved again and kept for the next requests. Files on private networks are forbidden
+func (p *Proxy) ServeJob(w http.ResponseWriter, r *http.Request, id string) {
+	job, added, err := p.lookup(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if job.Completed() {
+		serveFile(w, r, job.File)
+		return
+	}
+	if job.State == StateRunning && job.File != "" && job.Size > 0 {
+		p.servePartial(w, r, job)
+		return
+	}
+	resp, err := p.open(r, job)
+	if errors.Is(err, ErrLinkExpired) && r.Context().Err() == nil {
+		log.Infof("Stream link of %s expired, resolving it again", job.Title)
+		if refreshErr := job.RefreshURL(r.Context()); refreshErr != nil {
+			err = fmt.Errorf("%w, and it could not be refreshed: %v", err, refreshErr)
+		} else {
+			p.refreshed(job, added)
+			resp, err = p.open(r, job)
+		}
+	}
+	if err != nil {
+		if r.Context().Err() == nil {
+			log.Errorf("Could not stream %s: %v", job.Title, err)
+			status := http.StatusBadGateway
+			if errors.Is(err, ErrPrivateAddress) {
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
+		}
+		return
+	}
+	defer resp.Body.Close()
+	relay(w, r, job, resp)
+}
+
+// servePartial : respond to r with the file of job while it is downloaded, from the bytes already
+// on disk. Reads of bytes that are not downloaded yet wait for them, and ask the manager to download
+// them first when the job is running in this process
+func (p *Proxy) servePartial(w http.ResponseWriter, r *http.Request, job Job) {
+	var asked int64 = -1
+	file, err := OpenPartial(r.Context(), job, func(offset int64) error {
+		if offset != asked && p.manager.Prioritize(job.ID, offset) {
+			asked = offset
+		}
+		current, err := p.manager.Store().Get(job.ID)
+		if err != nil {
+			return err
+		}
+		switch current.State {
+		case StateRunning, StateQueued, StateCompleted:
+			return nil
+		}
+		return fmt.Errorf("download of %s is %s", job.Title, current.State)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	if contentType := ContentType(job.File); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	log.Debugf("Streaming %s while it is downloaded", job.Title)
+	http.ServeContent(w, r, filepath.Base(job.File), time.Time{}, file)
+}
+
+// open : request the file of job from its link, with the range asked by r and the headers of job
+// File hosts often check that their files are linked from the page of the movie, which is sent as
+// the Referer unless the job has its own
+func (p *Proxy) open(r *http.Request, job Job) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, job.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if job.DetailURL != "" {
+		req.Header.Set("Referer", job.DetailURL)
+	}
+	setHeaders(req, job.Headers)
+	// Compressed responses cannot be served by range
+	req.Header.Set("Accept-Encoding", "identity")
+	for _, name := range []string{"Range", "If-Range"} {
+		if value := r.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s: %s", ErrLinkExpired, job.URL, resp.Status)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", job.URL, resp.Status)
+	}
+	// Expired links of file hosts usually lead to an html page instead of the file
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s is a web page", ErrLinkExpired, job.URL)
+	}
+	return resp, nil
+}
+
+// relay : send the file in resp to w, with the content type of its name when the file host does
+// not give one. The range asked by r is cut from the whole file when the file host ignored it
+func relay(w http.ResponseWriter, r *http.Request, job Job, resp *http.Response) {
+	header := w.Header()
+	for _, name := range []string{"Content-Length", "Content-Range", "Last-Modified", "ETag"} {
+		if value := resp.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "" || mediaType == "application/octet-stream" || mediaType == "binary/octet-stream" {
+		if guessed := ContentType(fileName(resp, job.Title)); guessed != "" {
+			contentType = guessed
+		}
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	status := resp.StatusCode
+	var body io.Reader = resp.Body
+	switch {
+	case status == http.StatusPartialContent:
+		header.Set("Accept-Ranges", "bytes")
+	case status == http.StatusOK && resp.ContentLength > 0:
+		header.Set("Accept-Ranges", "bytes")
+		start, end, ok := parseRange(r.Header.Get("Range"), resp.ContentLength)
+		if !ok || r.Header.Get("If-Range") != "" {
+			break
+		}
+		if r.Method != http.MethodHead {
+			if _, err := io.CopyN(ioutil.Discard, resp.Body, start); err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+		}
+		body = io.LimitReader(resp.Body, end-start+1)
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, resp.ContentLength))
+		header.Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		// Players close the connection whenever they seek
+		log.Debugf("Stream of %s stopped: %v", job.Title, err)
+	}
+}
+
+// parseRange : the first and last byte of a single range header e.g `bytes=100-` of a file of size
+// Several ranges are not supported and are answered with the whole file
+func parseRange(value string, size int64) (start, end int64, ok bool) {
+	spec := strings.TrimPrefix(value, "bytes=")
+	if spec == value || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	i := strings.Index(spec, "-")
+	if i < 0 {
+		return 0, 0, false
+	}
+	first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+	if first == "" {
+		// The last bytes of the file
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end = size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
+
+// serveFile : respond to r with the downloaded file, or the part of it asked by r
+func serveFile(w http.ResponseWriter, r *http.Request, file string) {
+	f, err := os.Open(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if contentType := ContentType(file); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	http.ServeContent(w, r, filepath.Base(file), info.ModTime(), f)
+}
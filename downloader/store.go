@@ -0,0 +1,316 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Errors of the download queue
+var (
+	// ErrJobNotFound : no job is stored with the given id
+	ErrJobNotFound = errors.New("download not found")
+	// ErrAlreadyDownloaded : the file of the job is already downloaded
+	ErrAlreadyDownloaded = errors.New("already downloaded")
+)
+
+// Store : a JSON file of download jobs that can be shared by several gophie processes
+// Every access holds an exclusive lock on the file, and changes are written to a temporary
+// file that replaces the store so that it is never left half written
+type Store struct {
+	path string
+	mu   sync.Mutex // Serializes access within the process, the file lock only works across processes
+}
+
+// NewStore : open the store of download jobs at file
+func NewStore(file string) *Store {
+	return &Store{path: file}
+}
+
+// DefaultStore : the store of download jobs in the config dir
+// The downloads listed by earlier versions of gophie are added to it the first time it is used
+func DefaultStore() *Store {
+	store := NewStore(path.Join(viper.GetString("config-dir"), "downloads.json"))
+	importLegacyOnce.Do(func() {
+		for _, file := range legacyDownloadLists() {
+			n, err := store.importLegacyDownloads(file)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					log.Warnf("Could not import the downloads of an earlier version of gophie: %v", err)
+				}
+				continue
+			}
+			log.Infof("Imported %d downloads of an earlier version of gophie from %s, continue them with `gophie resume`", n, file)
+		}
+	})
+	return store
+}
+
+// legacyDownload : a download listed by versions of gophie before the download queue
+type legacyDownload struct {
+	URL    string
+	Dir    string
+	Name   string
+	Source string
+	Size   int64
+}
+
+// The downloads listed by earlier versions are imported once per process
+var importLegacyOnce sync.Once
+
+// legacyDownloadLists : the files earlier versions of gophie listed their downloads in
+func legacyDownloadLists() []string {
+	var files []string
+	for _, dir := range []string{viper.GetString("gophie_cache"), viper.GetString("cache-dir")} {
+		file := filepath.Join(dir, "downloadList.json")
+		if len(files) == 0 || files[0] != file {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// importLegacyDownloads : add the downloads listed in file by an earlier version of gophie to the
+// store as paused jobs, then rename file so that they are only imported once. Downloads of links
+// already in the store are skipped. The number of imported downloads is returned
+func (s *Store) importLegacyDownloads(file string) (int, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	var downloads []legacyDownload
+	// Earlier versions created the list empty
+	if strings.TrimSpace(string(content)) != "" {
+		if err = json.Unmarshal(content, &downloads); err != nil {
+			return 0, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	imported := 0
+	err = s.Update(func(jobs map[string]*Job) error {
+		known := map[string]bool{}
+		for _, job := range jobs {
+			known[job.URL] = true
+		}
+		now := time.Now()
+		for _, download := range downloads {
+			if download.URL == "" || known[download.URL] {
+				continue
+			}
+			known[download.URL] = true
+			job := &Job{
+				ID:        newJobID(),
+				Title:     download.Name,
+				URL:       download.URL,
+				Dir:       download.Dir,
+				Source:    download.Source,
+				Engine:    strings.ToLower(download.Source),
+				Size:      download.Size,
+				State:     StatePaused,
+				CreatedAt: now.Add(time.Duration(imported)),
+				UpdatedAt: now,
+			}
+			jobs[job.ID] = job
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return imported, os.Rename(file, file+".imported")
+}
+
+// Path : the file of the store
+func (s *Store) Path() string {
+	return s.path
+}
+
+// withLock : call fn while holding the locks of the store
+func (s *Store) withLock(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return lockPath(s.path, fn)
+}
+
+// lockPath : call fn while holding an exclusive lock on file shared by gophie processes
+func lockPath(file string, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(file+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err = lockFile(lock); err != nil {
+		return fmt.Errorf("could not lock %s: %v", file, err)
+	}
+	defer unlockFile(lock)
+	return fn()
+}
+
+// read : the jobs in the store by id
+func (s *Store) read() (map[string]*Job, error) {
+	jobs := make(map[string]*Job)
+	content, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return jobs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*Job
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &list); err != nil {
+			return nil, fmt.Errorf("%s: %v", s.path, err)
+		}
+	}
+	for _, job := range list {
+		jobs[job.ID] = job
+	}
+	return jobs, nil
+}
+
+// write : atomically replace the store with jobs
+func (s *Store) write(jobs map[string]*Job) error {
+	content, err := json.MarshalIndent(sortJobs(jobs), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAtomic(s.path, content)
+}
+
+// writeAtomic : replace file with content through a temporary file so that it is never left half written
+func writeAtomic(file string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Update : call fn with the jobs in the store by id and save the changes it makes
+// Nothing is saved if fn returns an error
+func (s *Store) Update(fn func(jobs map[string]*Job) error) error {
+	return s.withLock(func() error {
+		jobs, err := s.read()
+		if err != nil {
+			return err
+		}
+		if err = fn(jobs); err != nil {
+			return err
+		}
+		return s.write(jobs)
+	})
+}
+
+// View : call fn with the jobs in the store by id, changes made by fn are not saved
+func (s *Store) View(fn func(jobs map[string]*Job) error) error {
+	return s.withLock(func() error {
+		jobs, err := s.read()
+		if err != nil {
+			return err
+		}
+		return fn(jobs)
+	})
+}
+
+// List : all the jobs in the store in the order they were added
+func (s *Store) List() ([]Job, error) {
+	var list []Job
+	err := s.View(func(jobs map[string]*Job) error {
+		for _, job := range sortJobs(jobs) {
+			list = append(list, *job)
+		}
+		return nil
+	})
+	return list, err
+}
+
+// Get : the job stored with id
+func (s *Store) Get(id string) (Job, error) {
+	var job Job
+	err := s.View(func(jobs map[string]*Job) error {
+		j, ok := jobs[id]
+		if !ok {
+			return ErrJobNotFound
+		}
+		job = *j
+		return nil
+	})
+	return job, err
+}
+
+// Find : the job downloading the same file as job, which has the same link, or the same title
+// and detail page when links change on every visit
+func (s *Store) Find(job Job) (Job, error) {
+	var found Job
+	err := s.View(func(jobs map[string]*Job) error {
+		for _, j := range sortJobs(jobs) {
+			if j.URL == job.URL ||
+				(job.DetailURL != "" && j.DetailURL == job.DetailURL && j.Title == job.Title) {
+				found = *j
+				return nil
+			}
+		}
+		return ErrJobNotFound
+	})
+	return found, err
+}
+
+// Put : add job to the store or replace the job with its id
+func (s *Store) Put(job Job) error {
+	return s.Update(func(jobs map[string]*Job) error {
+		jobs[job.ID] = &job
+		return nil
+	})
+}
+
+// Delete : remove the job stored with id
+func (s *Store) Delete(id string) error {
+	return s.Update(func(jobs map[string]*Job) error {
+		if _, ok := jobs[id]; !ok {
+			return ErrJobNotFound
+		}
+		delete(jobs, id)
+		return nil
+	})
+}
+
+// sortJobs : jobs in the order they were added
+func sortJobs(jobs map[string]*Job) []*Job {
+	list := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, job)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].ID < list[j].ID
+		}
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
@@ -0,0 +1,135 @@
+package downloader
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Extensions of subtitles kept as served, other subtitles are saved as .srt
+var subtitleExts = []string{".srt", ".vtt", ".ass", ".ssa", ".sub"}
+
+// Extensions of the subtitles extracted from zipped subtitles
+var zippedSubtitleExts = []string{".srt", ".vtt"}
+
+// DownloadSubtitle : download the subtitle at link next to file, with the name of file and the
+// extension of the subtitle e.g `Movie.mp4` gets `Movie.srt`. Zipped subtitles are replaced by the
+// first .srt or .vtt subtitle in them. The path of the subtitle is returned
+func DownloadSubtitle(ctx context.Context, client *http.Client, link, file string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", link, resp.Status)
+	}
+	// Subtitles behind expired links lead to an html page
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return "", fmt.Errorf("%w: %s is a web page", ErrLinkExpired, link)
+	}
+
+	name := strings.TrimSuffix(file, filepath.Ext(file))
+	served := strings.ToLower(filepath.Ext(fileName(resp, "")))
+	if served == ".zip" || resp.Header.Get("Content-Type") == "application/zip" {
+		return extractSubtitle(resp.Body, name)
+	}
+	ext := ".srt"
+	if contains(subtitleExts, served) {
+		ext = served
+	}
+	return name + ext, saveSubtitle(resp.Body, name+ext)
+}
+
+// extractSubtitle : save the first .srt or .vtt subtitle of the zip archive in r as name with the
+// extension of the subtitle. The path of the subtitle is returned
+func extractSubtitle(r io.Reader, name string) (string, error) {
+	// Zip archives are read from their end, so they are kept on disk meanwhile
+	archive, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	size, err := io.Copy(archive, r)
+	if err != nil {
+		return "", err
+	}
+	zipped, err := zip.NewReader(archive, size)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range zipped.File {
+		ext := strings.ToLower(filepath.Ext(f.Name))
+		if f.FileInfo().IsDir() || !contains(zippedSubtitleExts, ext) {
+			continue
+		}
+		content, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer content.Close()
+		return name + ext, saveSubtitle(content, name+ext)
+	}
+	return "", errors.New("the zipped subtitle has no .srt or .vtt file")
+}
+
+// saveSubtitle : write the subtitle in r to path, which is only replaced once it is complete
+func saveSubtitle(r io.Reader, path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// downloadJobSubtitle : download the subtitle of a downloaded job if it was asked for
+// Movies are still usable without their subtitle so failures are only logged
+func downloadJobSubtitle(ctx context.Context, job *Job, client *http.Client) {
+	if !job.Subtitles || job.SubtitleURL == "" || job.File == "" {
+		return
+	}
+	if job.SubtitleFile != "" {
+		if _, err := os.Stat(job.SubtitleFile); err == nil {
+			return
+		}
+	}
+	subtitle, err := DownloadSubtitle(ctx, client, job.SubtitleURL, job.File)
+	if err != nil {
+		log.Warnf("Could not download the subtitle of %s: %v", job.Title, err)
+		return
+	}
+	log.Infof("Downloaded the subtitle of %s to %s", job.Title, subtitle)
+	job.SubtitleFile = subtitle
+}
+
+// contains : reports whether list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
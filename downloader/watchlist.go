@@ -0,0 +1,308 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-phie/gophie/engine"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Errors of the watchlist
+var (
+	// ErrSubscriptionNotFound : no subscription is stored with the given id
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrAlreadyWatched : the same search of the same engine is already watched
+	ErrAlreadyWatched = errors.New("already watched")
+)
+
+// Number of movies and episodes remembered by a subscription, the oldest are forgotten first
+const maxSeen = 2000
+
+// Subscription : a search of an engine, or its latest movies, watched for new movies and episodes
+type Subscription struct {
+	ID        string
+	Engine    string
+	Query     string    `json:",omitempty"` // Searched on the engine, the latest movies are watched if empty
+	Quality   string    `json:",omitempty"` // Preferred quality of movies available in several
+	Subtitles bool      `json:",omitempty"` // Download subtitles next to the movies
+	Notify    bool      `json:",omitempty"` // Only report new movies and episodes without downloading them
+	Seen      []string  // Movies and episodes already found, in the order they were found
+	Added     time.Time // When the subscription was added
+	Checked   time.Time // Last time the engine was checked, zero until the first check
+}
+
+// Release : a movie, or an episode of a series, found by a subscription
+type Release struct {
+	Key     string // Identifies the release amongst those seen by the subscription
+	Movie   engine.Movie
+	Episode *engine.Episode // Episode of the series if the release is an episode
+}
+
+// Title : the title of the movie or of the episode of the release e.g `Show S01E03`
+func (r Release) Title() string {
+	if r.Episode != nil {
+		return fmt.Sprintf("%s %s", strings.TrimSpace(r.Movie.Title), r.Episode.Code())
+	}
+	return r.Movie.Title
+}
+
+// Link : the download link of the release
+func (r Release) Link() string {
+	link := r.Movie.DownloadLink
+	if r.Episode != nil {
+		link = r.Episode.Link
+	}
+	if link == nil {
+		return ""
+	}
+	return link.String()
+}
+
+// Job : a job to download the release to outputDir, in quality if it is available in several
+func (r Release) Job(outputDir, quality string) Job {
+	if r.Episode != nil {
+		episode := *r.Episode
+		if variant, err := engine.SelectVariant(episode.Variants, quality, 0); len(episode.Variants) > 0 && err == nil {
+			episode.Link, episode.Size = variant.Link, variant.Size
+		}
+		return NewEpisodeJob(&r.Movie, episode, outputDir)
+	}
+	movie := r.Movie
+	if variant, err := engine.SelectVariant(movie.Variants, quality, 0); len(movie.Variants) > 0 && err == nil {
+		movie.DownloadLink, movie.Size = variant.Link, variant.Size
+	}
+	return NewJob(&movie, outputDir)
+}
+
+// Releases : the movies and episodes of result that match the query of the subscription
+// Series are split in their episodes, while a single episode outside of a series is a movie
+func (s *Subscription) Releases(result engine.SearchResult) []Release {
+	var releases []Release
+	for _, movie := range result.Movies {
+		if !engine.MatchesQuery(s.Query, movie.Title) {
+			continue
+		}
+		episodes := movie.Episodes()
+		if len(episodes) > 1 || (len(episodes) == 1 && movie.IsSeries) {
+			for i := range episodes {
+				releases = append(releases, Release{
+					Key:     fmt.Sprintf("%s %s", movie.Title, episodes[i].Code()),
+					Movie:   movie,
+					Episode: &episodes[i],
+				})
+			}
+			continue
+		}
+		releases = append(releases, Release{Key: movie.Title, Movie: movie})
+	}
+	return releases
+}
+
+// NewReleases : the releases of result not seen before by the subscription, which are now seen
+// Nothing is new on the first check, which only records what was already released
+func (s *Subscription) NewReleases(result engine.SearchResult) []Release {
+	var releases []Release
+	first := s.Checked.IsZero()
+	for _, release := range s.Releases(result) {
+		if contains(s.Seen, release.Key) {
+			continue
+		}
+		s.Seen = append(s.Seen, release.Key)
+		if !first {
+			releases = append(releases, release)
+		}
+	}
+	if len(s.Seen) > maxSeen {
+		s.Seen = s.Seen[len(s.Seen)-maxSeen:]
+	}
+	s.Checked = time.Now()
+	return releases
+}
+
+// Check : search or list the engine of the subscription for its new releases
+func (s *Subscription) Check(ctx context.Context) ([]Release, error) {
+	e, err := engine.GetEngine(s.Engine)
+	if err != nil {
+		return nil, err
+	}
+	var result engine.SearchResult
+	if s.Query == "" {
+		result, err = e.List(ctx, 1)
+	} else {
+		result, err = e.Search(ctx, s.Query)
+	}
+	if err != nil && !errors.Is(err, engine.ErrNoResults) {
+		return nil, err
+	}
+	return s.NewReleases(result), nil
+}
+
+// Watchlist : a JSON file of subscriptions that can be shared by several gophie processes
+type Watchlist struct {
+	path string
+	mu   sync.Mutex // Serializes access within the process, the file lock only works across processes
+}
+
+// NewWatchlist : open the watchlist at file
+func NewWatchlist(file string) *Watchlist {
+	return &Watchlist{path: file}
+}
+
+// DefaultWatchlist : the watchlist in the config dir
+func DefaultWatchlist() *Watchlist {
+	return NewWatchlist(path.Join(viper.GetString("config-dir"), "watchlist.json"))
+}
+
+// read : the subscriptions in the watchlist in the order they were added
+func (w *Watchlist) read() ([]Subscription, error) {
+	var subscriptions []Subscription
+	content, err := ioutil.ReadFile(w.path)
+	if os.IsNotExist(err) {
+		return subscriptions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &subscriptions); err != nil {
+			return nil, fmt.Errorf("%s: %v", w.path, err)
+		}
+	}
+	return subscriptions, nil
+}
+
+// Update : call fn with the subscriptions in the watchlist and save the subscriptions it returns
+// Nothing is saved if fn returns an error
+func (w *Watchlist) Update(fn func(subscriptions []Subscription) ([]Subscription, error)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return lockPath(w.path, func() error {
+		subscriptions, err := w.read()
+		if err != nil {
+			return err
+		}
+		if subscriptions, err = fn(subscriptions); err != nil {
+			return err
+		}
+		if subscriptions == nil {
+			subscriptions = []Subscription{}
+		}
+		content, err := json.MarshalIndent(subscriptions, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeAtomic(w.path, content)
+	})
+}
+
+// List : all the subscriptions in the order they were added
+func (w *Watchlist) List() ([]Subscription, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var list []Subscription
+	err := lockPath(w.path, func() error {
+		var err error
+		list, err = w.read()
+		return err
+	})
+	return list, err
+}
+
+// Add : watch sub, unless the same search of the same engine is already watched
+func (w *Watchlist) Add(sub Subscription) (Subscription, error) {
+	sub.Engine = strings.ToLower(sub.Engine)
+	sub.Query = strings.TrimSpace(sub.Query)
+	err := w.Update(func(subscriptions []Subscription) ([]Subscription, error) {
+		for _, s := range subscriptions {
+			if s.Engine == sub.Engine && strings.EqualFold(s.Query, sub.Query) {
+				return nil, fmt.Errorf("%s on %s is %w as %s", sub.Query, sub.Engine, ErrAlreadyWatched, s.ID)
+			}
+		}
+		sub.ID = newJobID()
+		sub.Added = time.Now()
+		return append(subscriptions, sub), nil
+	})
+	return sub, err
+}
+
+// Remove : stop watching the subscription with id
+func (w *Watchlist) Remove(id string) error {
+	return w.Update(func(subscriptions []Subscription) ([]Subscription, error) {
+		for i, s := range subscriptions {
+			if s.ID == id {
+				return append(subscriptions[:i], subscriptions[i+1:]...), nil
+			}
+		}
+		return nil, ErrSubscriptionNotFound
+	})
+}
+
+// save : store the movies and episodes seen by sub and when it was checked
+// Subscriptions removed while they were checked are not added back
+func (w *Watchlist) save(sub Subscription) error {
+	return w.Update(func(subscriptions []Subscription) ([]Subscription, error) {
+		for i := range subscriptions {
+			if subscriptions[i].ID == sub.ID {
+				subscriptions[i].Seen = sub.Seen
+				subscriptions[i].Checked = sub.Checked
+			}
+		}
+		return subscriptions, nil
+	})
+}
+
+// Check : check every subscription for new releases, calling found with each of them, and queue the
+// new releases of subscriptions that are not only notified to download them to outputDir with manager
+// Subscriptions whose engine fails are logged and checked again the next time
+func (w *Watchlist) Check(ctx context.Context, manager *Manager, outputDir string, found func(sub Subscription, release Release)) ([]Job, error) {
+	subscriptions, err := w.List()
+	if err != nil {
+		return nil, err
+	}
+	var queued []Job
+	for _, sub := range subscriptions {
+		releases, err := sub.Check(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return queued, ctx.Err()
+			}
+			log.Errorf("Could not check %s on %s: %v", sub.Query, sub.Engine, err)
+			continue
+		}
+		for _, release := range releases {
+			found(sub, release)
+			if sub.Notify {
+				continue
+			}
+			if release.Link() == "" {
+				log.Warnf("%s has no download link", release.Title())
+				continue
+			}
+			job := release.Job(outputDir, sub.Quality)
+			job.Subtitles = sub.Subtitles
+			job, err = manager.Queue(ctx, job, false)
+			if errors.Is(err, ErrAlreadyDownloaded) {
+				log.Infof("Skipping %v", err)
+				continue
+			}
+			if err != nil {
+				return queued, err
+			}
+			queued = append(queued, job)
+		}
+		if err = w.save(sub); err != nil {
+			return queued, err
+		}
+	}
+	return queued, nil
+}
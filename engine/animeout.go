@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"path"
@@ -53,11 +54,11 @@ func (engine *AnimeOut) String() string {
 	return st
 }
 
-func (engine *AnimeOut) getParseAttrs() (string, string, error) {
+func (engine *AnimeOut) GetParseAttrs(req *Request) (string, string, error) {
 	return "div.container", "article.post-item", nil
 }
 
-func (engine *AnimeOut) parseSingleMovie(el *colly.HTMLElement, index int) (Movie, error) {
+func (engine *AnimeOut) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
 	movie := Movie{
 		Index:    index,
 		IsSeries: true,
@@ -69,15 +70,15 @@ func (engine *AnimeOut) parseSingleMovie(el *colly.HTMLElement, index int) (Movi
 	downloadLink, err := url.Parse(el.Request.AbsoluteURL(el.ChildAttr("a", "href")))
 
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 	movie.DownloadLink = downloadLink
 	return movie, nil
 }
 
-func (engine *AnimeOut) updateDownloadProps(downloadCollector *colly.Collector, movies *[]Movie) {
+func (engine *AnimeOut) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
 	downloadCollector.OnHTML("div.article-content", func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		description := e.ChildText("div.spaceit")
 		episodeMap := map[string]*url.URL{}
 		if description == "" {
@@ -118,39 +119,40 @@ func (engine *AnimeOut) updateDownloadProps(downloadCollector *colly.Collector,
 
 		movie.Description = description
 		movie.SDownloadLink = episodeMap
+		movie.Seasons = GroupSeasons(EpisodesFromLinks(movie.Title, episodeMap, nil))
 	})
 }
 
 // List : list all the movies on a page
-func (engine *AnimeOut) List(page int) SearchResult {
-	engine.mode = ListMode
+func (engine *AnimeOut) List(ctx context.Context, page int) (SearchResult, error) {
+	req := engine.NewListRequest(page)
 	result := SearchResult{
 		Query: "List of Recent Uploads - Page " + strconv.Itoa(page),
 	}
 	pageParam := fmt.Sprintf("page/%v", strconv.Itoa(page))
-	engine.ListURL.Path = path.Join(engine.ListURL.Path, pageParam)
-	movies, err := Scrape(engine)
+	req.URL.Path = path.Join(req.URL.Path, pageParam)
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
 
 // Search : Searches fzmovies for a particular query and return an array of movies
-func (engine *AnimeOut) Search(param ...string) SearchResult {
+func (engine *AnimeOut) Search(ctx context.Context, param ...string) (SearchResult, error) {
 	query := param[0]
-	engine.mode = SearchMode
+	req := engine.NewSearchRequest(query)
 	result := SearchResult{
 		Query: query,
 	}
-	q := engine.SearchURL.Query()
+	q := req.URL.Query()
 	q.Set("s", query)
-	engine.SearchURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
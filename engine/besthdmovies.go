@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"path"
@@ -53,11 +54,11 @@ func (engine *BestHDEngine) String() string {
 	return st
 }
 
-func (engine *BestHDEngine) getParseAttrs() (string, string, error) {
+func (engine *BestHDEngine) GetParseAttrs(req *Request) (string, string, error) {
 	return "body", "article.latestPost", nil
 }
 
-func (engine *BestHDEngine) parseSingleMovie(el *colly.HTMLElement, index int) (Movie, error) {
+func (engine *BestHDEngine) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
 	movie := Movie{
 		Index:    index,
 		IsSeries: false,
@@ -65,7 +66,7 @@ func (engine *BestHDEngine) parseSingleMovie(el *colly.HTMLElement, index int) (
 	}
 	cover, err := url.Parse(el.Request.AbsoluteURL(el.ChildAttr("img", "src")))
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 	re := regexp.MustCompile(`\d+`)
 	movieYear := re.FindStringSubmatch(el.ChildText("div.categories"))
@@ -80,7 +81,7 @@ func (engine *BestHDEngine) parseSingleMovie(el *colly.HTMLElement, index int) (
 	downloadLink, err := url.Parse(el.ChildAttr("a", "href"))
 
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 	// download link is current link path + /download
 	downloadLink.Path = path.Join(engine.BaseURL.Path, downloadLink.Path)
@@ -89,12 +90,16 @@ func (engine *BestHDEngine) parseSingleMovie(el *colly.HTMLElement, index int) (
 	return movie, nil
 }
 
-func (engine *BestHDEngine) updateDownloadProps(downloadCollector *colly.Collector, movies *[]Movie) {
+func (engine *BestHDEngine) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
 	//  submissionDetails := make(map[string]string)
 	// Update movie download link if div.post-single-content  on page
 	downloadCollector.OnHTML("div.post-single-content", func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		ptags := e.ChildTexts("p")
+		if len(ptags) < 3 {
+			log.Errorf("Unexpected layout for %v", e.Request.URL)
+			return
+		}
 		if ptags[len(ptags)-3] >= ptags[len(ptags)-2] {
 			movie.Description = strings.TrimSpace(ptags[len(ptags)-3])
 		} else {
@@ -116,14 +121,15 @@ func (engine *BestHDEngine) updateDownloadProps(downloadCollector *colly.Collect
 					movie.DownloadLink = downloadlink
 					downloadCollector.Visit(downloadlink.String())
 				} else {
-					log.Fatal(err)
+					log.Error(err)
+					return
 				}
 			}
 		}
 	})
 
 	downloadCollector.OnHTML("div.content-area", func(e *colly.HTMLElement) {
-		movieIndex := getMovieIndexFromCtx(e.Request)
+		movieIndex := GetMovieIndexFromCtx(e.Request)
 		movie := &(*movies)[movieIndex]
 		links := e.ChildAttrs("a", "href")
 		for _, link := range links {
@@ -138,14 +144,15 @@ func (engine *BestHDEngine) updateDownloadProps(downloadCollector *colly.Collect
 					movie.DownloadLink = downloadlink
 					downloadCollector.Visit(downloadlink.String())
 				} else {
-					log.Fatal(err)
+					log.Error(err)
+					return
 				}
 			}
 		}
 	})
 
 	downloadCollector.OnHTML("div.freeDownload", func(e *colly.HTMLElement) {
-		movieIndex := getMovieIndexFromCtx(e.Request)
+		movieIndex := GetMovieIndexFromCtx(e.Request)
 		movie := &(*movies)[movieIndex]
 		if e.ChildAttr("a.link_button", "href") != "" {
 			downloadlink, err := url.Parse(e.ChildAttr("a.link_button", "href"))
@@ -153,20 +160,21 @@ func (engine *BestHDEngine) updateDownloadProps(downloadCollector *colly.Collect
 				movie.DownloadLink = downloadlink
 			}
 		} else {
-			zeesubmission := getFormDetails(e)
+			zeesubmission := GetFormDetails(e)
 			err := downloadCollector.Post(movie.DownloadLink.String(), zeesubmission)
 			if err != nil {
-				log.Fatal(err)
+				log.Error(err)
+				return
 			}
 		}
 	})
 
 	downloadCollector.OnHTML("form[method=post]", func(e *colly.HTMLElement) {
-		movieIndex := getMovieIndexFromCtx(e.Request)
+		movieIndex := GetMovieIndexFromCtx(e.Request)
 		var err error
 		movie := &(*movies)[movieIndex]
 		downloadlink := movie.DownloadLink
-		submissionDetails := getFormDetails(e)
+		submissionDetails := GetFormDetails(e)
 		requestlink := e.Request.URL.String()
 		if !(strings.HasPrefix(requestlink, "https://zeefiles") || strings.HasPrefix(requestlink, "http://zeefiles")) {
 			downloadlink, err = url.Parse("https://freeload.fun/downloading/?movieIndex=" + strconv.Itoa(movieIndex))
@@ -175,14 +183,15 @@ func (engine *BestHDEngine) updateDownloadProps(downloadCollector *colly.Collect
 			}
 			err = downloadCollector.Post(downloadlink.String(), submissionDetails)
 			if err != nil {
-				log.Fatal(err)
+				log.Error(err)
+				return
 			}
 		}
 	})
 
 	downloadCollector.OnHTML("meta[http-equiv=refresh]", func(e *colly.HTMLElement) {
 		// Retrieve link when on freeload.fun/downloading
-		movieIndex := getMovieIndexFromCtx(e.Request)
+		movieIndex := GetMovieIndexFromCtx(e.Request)
 		movie := &(*movies)[movieIndex]
 		content := e.Attr("content")
 		re := regexp.MustCompile(`url=(.*)`)
@@ -195,18 +204,19 @@ func (engine *BestHDEngine) updateDownloadProps(downloadCollector *colly.Collect
 
 	downloadCollector.OnHTML("div.freeDownload", func(e *colly.HTMLElement) {
 		// Retrieve link when on zeefiles.download/id
-		movieIndex := getMovieIndexFromCtx(e.Request)
+		movieIndex := GetMovieIndexFromCtx(e.Request)
 		movie := &(*movies)[movieIndex]
 		linkButton := e.ChildAttr("a.link_button", "href")
 		if linkButton != "" {
 			movie.DownloadLink, _ = url.Parse(linkButton)
 		} else {
-			submissionDetails := getFormDetails(e)
+			submissionDetails := GetFormDetails(e)
 			downloadCollector.AllowURLRevisit = true
 			if !strings.Contains(movie.DownloadLink.String(), "download_token") {
 				err := downloadCollector.Post(movie.DownloadLink.String(), submissionDetails)
 				if err != nil {
-					log.Fatal(err)
+					log.Error(err)
+					return
 				}
 			}
 		}
@@ -214,43 +224,43 @@ func (engine *BestHDEngine) updateDownloadProps(downloadCollector *colly.Collect
 
 	downloadCollector.OnHTML("video", func(e *colly.HTMLElement) {
 		downloadlink := e.ChildAttr("source", "src")
-		movieIndex := getMovieIndexFromCtx(e.Request)
+		movieIndex := GetMovieIndexFromCtx(e.Request)
 		movie := &(*movies)[movieIndex]
 		movie.DownloadLink, _ = url.Parse(downloadlink)
 	})
 }
 
 // List : list all the movies on a page
-func (engine *BestHDEngine) List(page int) SearchResult {
-	engine.mode = ListMode
+func (engine *BestHDEngine) List(ctx context.Context, page int) (SearchResult, error) {
+	req := engine.NewListRequest(page)
 	result := SearchResult{
 		Query: "List of Recent Uploads - Page " + strconv.Itoa(page),
 	}
 
 	pageParam := fmt.Sprintf("page/%v", strconv.Itoa(page))
-	engine.ListURL.Path = path.Join(engine.ListURL.Path, pageParam)
-	movies, err := Scrape(engine)
+	req.URL.Path = path.Join(req.URL.Path, pageParam)
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
 
 // Search : Searches netnaija for a particular query and return an array of movies
-func (engine *BestHDEngine) Search(param ...string) SearchResult {
+func (engine *BestHDEngine) Search(ctx context.Context, param ...string) (SearchResult, error) {
 	query := param[0]
-	engine.mode = SearchMode
+	req := engine.NewSearchRequest(query)
 	result := SearchResult{
 		Query: query,
 	}
-	q := engine.SearchURL.Query()
+	q := req.URL.Query()
 	q.Set("s", query)
-	engine.SearchURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Characters that cannot be used in environment variable names
+var unsafeEnvRe = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// propsGetter : implemented by engines that embed Props
+type propsGetter interface {
+	GetProps() *Props
+}
+
+// configKey : the viper key of an engine setting and the environment variable it is read from
+// e.g the base_url of mycoolmoviez is read from engines.mycoolmoviez.base_url or GOPHIE_MYCOOLMOVIEZ_BASE_URL
+func configKey(name, setting string) (string, string) {
+	name = strings.ToLower(name)
+	env := strings.ToUpper(unsafeEnvRe.ReplaceAllString("gophie_"+name+"_"+setting, "_"))
+	return fmt.Sprintf("engines.%s.%s", name, setting), env
+}
+
+// configString : the value of an engine setting from the environment, or else from config
+// The environment is read directly rather than bound in viper, as engines are configured concurrently
+func configString(name, setting string) string {
+	key, env := configKey(name, setting)
+	if value, ok := os.LookupEnv(env); ok {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(viper.GetString(key))
+}
+
+// configList : the values of an engine setting separated by commas or spaces in the environment,
+// or else given as a list in config
+func configList(name, setting string) []string {
+	key, env := configKey(name, setting)
+	list := viper.GetStringSlice(key)
+	if value, ok := os.LookupEnv(env); ok {
+		list = strings.Fields(value)
+	}
+	var values []string
+	for _, value := range list {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// parseBaseURL : parse an absolute url used as the base url of an engine
+func parseBaseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return nil, fmt.Errorf("%q must be an absolute url", raw)
+	}
+	return u, nil
+}
+
+// rebase : a copy of u on the scheme and host of base
+func rebase(u, base *url.URL) *url.URL {
+	c := copyURL(u)
+	c.Scheme = base.Scheme
+	c.Host = base.Host
+	c.User = base.User
+	return c
+}
+
+// Configure : override the urls and mirrors of an engine registered under name from config
+//
+//	engines:
+//	  besthdmovies:
+//	    base_url: https://www.besthdmovies.top/
+//	    mirrors: [https://besthdmovies.fun/]
+//
+// or the GOPHIE_<ENGINE>_BASE_URL, GOPHIE_<ENGINE>_SEARCH_URL, GOPHIE_<ENGINE>_LIST_URL and
+// GOPHIE_<ENGINE>_MIRRORS environment variables. A new base url moves the search and list urls
+// to its host. Engines that do not embed Props are returned unchanged
+func Configure(name string, e Engine) Engine {
+	getter, ok := e.(propsGetter)
+	if !ok {
+		return e
+	}
+	props := getter.GetProps()
+	if raw := configString(name, "base_url"); raw != "" {
+		if base, err := parseBaseURL(raw); err != nil {
+			log.Errorf("Invalid base_url for %s: %v", name, err)
+		} else {
+			if props.SearchURL != nil {
+				props.SearchURL = rebase(props.SearchURL, base)
+			}
+			if props.ListURL != nil {
+				props.ListURL = rebase(props.ListURL, base)
+			}
+			props.BaseURL = base
+		}
+	}
+	for setting, field := range map[string]**url.URL{
+		"search_url": &props.SearchURL,
+		"list_url":   &props.ListURL,
+	} {
+		if raw := configString(name, setting); raw != "" {
+			u, err := parseBaseURL(raw)
+			if err != nil {
+				log.Errorf("Invalid %s for %s: %v", setting, name, err)
+				continue
+			}
+			*field = u
+		}
+	}
+	if mirrors := configList(name, "mirrors"); len(mirrors) > 0 {
+		props.Mirrors = nil
+		for _, raw := range mirrors {
+			mirror, err := parseBaseURL(raw)
+			if err != nil {
+				log.Errorf("Invalid mirror for %s: %v", name, err)
+				continue
+			}
+			props.Mirrors = append(props.Mirrors, mirror)
+		}
+	}
+	return e
+}
+
+// shouldTryMirror : reports whether a scrape that failed with err may succeed on a mirror
+func shouldTryMirror(err error) bool {
+	return errors.Is(err, ErrEngineUnreachable) || errors.Is(err, ErrBlockedByCloudflare)
+}
+
+// mirrorsOf : the mirrors of an engine if it embeds Props
+func mirrorsOf(e Engine) []*url.URL {
+	if getter, ok := e.(propsGetter); ok {
+		return getter.GetProps().Mirrors
+	}
+	return nil
+}
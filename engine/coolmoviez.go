@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"path"
@@ -54,11 +55,11 @@ func (engine *CoolMoviez) String() string {
 	return st
 }
 
-func (engine *CoolMoviez) getParseAttrs() (string, string, error) {
+func (engine *CoolMoviez) GetParseAttrs(req *Request) (string, string, error) {
 	return "div.list", "div.fl", nil
 }
 
-func (engine *CoolMoviez) parseSingleMovie(el *colly.HTMLElement, index int) (Movie, error) {
+func (engine *CoolMoviez) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
 	movie := Movie{
 		Index:    index,
 		IsSeries: false,
@@ -80,18 +81,18 @@ func (engine *CoolMoviez) parseSingleMovie(el *colly.HTMLElement, index int) (Mo
 	movie.CoverPhotoLink = el.ChildAttr("img", "src")
 
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 
 	movie.DownloadLink = downloadLink
 	return movie, nil
 }
 
-func (engine *CoolMoviez) updateDownloadProps(downloadCollector *colly.Collector, movies *[]Movie) {
+func (engine *CoolMoviez) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
 
 	downloadCollector.OnHTML("div.M1,div.M2", func(e *colly.HTMLElement) {
 		reArray := []string{"Quality", "Genre", "Description", "Starcast"}
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		for _, reString := range reArray {
 			re := regexp.MustCompile(reString + `:\s+(.*)`)
 			stringsub := re.FindStringSubmatch(e.Text)
@@ -112,7 +113,7 @@ func (engine *CoolMoviez) updateDownloadProps(downloadCollector *colly.Collector
 	})
 
 	downloadCollector.OnHTML("a.fileName", func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		initialLink := e.Attr("href")
 		re := regexp.MustCompile(`Size:\s+(.*)`)
 		stringsub := re.FindStringSubmatch(e.Text)
@@ -120,18 +121,17 @@ func (engine *CoolMoviez) updateDownloadProps(downloadCollector *colly.Collector
 			movie.Size = stringsub[1]
 		}
 
-		replacePrefix := "https://www.coolmoviez.buzz/file"
-		if strings.HasPrefix(initialLink, replacePrefix) {
-			downloadLink, err := url.Parse("https://www.coolmoviez.buzz/server" + strings.TrimPrefix(initialLink, replacePrefix))
-			if err == nil {
-				movie.DownloadLink = downloadLink
-				downloadCollector.Visit(downloadLink.String())
-			}
+		// Files are served from /server on the same host as the /file page
+		downloadLink, err := url.Parse(e.Request.AbsoluteURL(initialLink))
+		if err == nil && strings.HasPrefix(downloadLink.Path, "/file") {
+			downloadLink.Path = "/server" + strings.TrimPrefix(downloadLink.Path, "/file")
+			movie.DownloadLink = downloadLink
+			downloadCollector.Visit(downloadLink.String())
 		}
 	})
 
 	downloadCollector.OnHTML("a.dwnLink", func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		downloadLink, err := url.Parse(e.Attr("href"))
 		if err == nil {
 			movie.DownloadLink = downloadLink
@@ -140,36 +140,36 @@ func (engine *CoolMoviez) updateDownloadProps(downloadCollector *colly.Collector
 }
 
 // List : list all the movies on a page
-func (engine *CoolMoviez) List(page int) SearchResult {
-	engine.mode = ListMode
+func (engine *CoolMoviez) List(ctx context.Context, page int) (SearchResult, error) {
+	req := engine.NewListRequest(page)
 	result := SearchResult{
 		Query: "List of Recent Uploads - Page " + strconv.Itoa(page),
 	}
 	pageParam := fmt.Sprintf("%v.html", strconv.Itoa(page))
-	engine.ListURL.Path = path.Join(engine.ListURL.Path, pageParam) + "/"
-	movies, err := Scrape(engine)
+	req.URL.Path = path.Join(req.URL.Path, pageParam) + "/"
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
 
 // Search : Searches fzmovies for a particular query and return an array of movies
-func (engine *CoolMoviez) Search(param ...string) SearchResult {
+func (engine *CoolMoviez) Search(ctx context.Context, param ...string) (SearchResult, error) {
 	query := param[0]
-	engine.mode = SearchMode
+	req := engine.NewSearchRequest(query)
 	result := SearchResult{
 		Query: query,
 	}
-	q := engine.SearchURL.Query()
+	q := req.URL.Query()
 	q.Set("find", query)
 	q.Set("per_page", "1")
-	engine.SearchURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
@@ -0,0 +1,346 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// DeclarativeConfig : description of an engine loaded from a YAML or JSON file
+//
+//	name: MySite
+//	base_url: https://mysite.com/
+//	mirrors: [https://mysite.net/]
+//	search:
+//	  path: /search
+//	  params: {q: "{query}", page: "{page}"}
+//	list:
+//	  path: /latest/{page}
+//	selectors:
+//	  container: main
+//	  item: article
+//	  title: h3
+//	  link: a
+//	  year_regex: '\((\d{4})\)'
+//	follow:
+//	  - selector: a.download
+type DeclarativeConfig struct {
+	Name        string              `json:"name" yaml:"name"`
+	Description string              `json:"description" yaml:"description"`
+	BaseURL     string              `json:"base_url" yaml:"base_url"`
+	Mirrors     []string            `json:"mirrors" yaml:"mirrors"` // Base URLs tried in order when base_url cannot be reached
+	Search      DeclarativeURL      `json:"search" yaml:"search"`
+	List        DeclarativeURL      `json:"list" yaml:"list"`
+	Selectors   DeclarativeSelector `json:"selectors" yaml:"selectors"`
+	// Pages followed in order from the link of a movie to its download link
+	Follow []DeclarativeFollow `json:"follow" yaml:"follow"`
+}
+
+// DeclarativeURL : path and query params of a search or list url
+// {query} and {page} are replaced with the query searched for and the page requested
+type DeclarativeURL struct {
+	Path   string            `json:"path" yaml:"path"`
+	Params map[string]string `json:"params" yaml:"params"`
+}
+
+// DeclarativeSelector : css selectors used to parse movies from a page
+// Selectors are relative to the item, an empty title selector uses the text of the item
+type DeclarativeSelector struct {
+	Container   string `json:"container" yaml:"container"` // e.g main, same as the first return of GetParseAttrs
+	Item        string `json:"item" yaml:"item"`           // e.g article, same as the second return of GetParseAttrs
+	Title       string `json:"title" yaml:"title"`
+	Link        string `json:"link" yaml:"link"`
+	LinkAttr    string `json:"link_attr" yaml:"link_attr"` // defaults to href
+	Cover       string `json:"cover" yaml:"cover"`
+	CoverAttr   string `json:"cover_attr" yaml:"cover_attr"` // defaults to src
+	Description string `json:"description" yaml:"description"`
+	Size        string `json:"size" yaml:"size"`
+	YearRegex   string `json:"year_regex" yaml:"year_regex"` // matched against the title
+	SizeRegex   string `json:"size_regex" yaml:"size_regex"` // matched against the size, or the item without a size selector
+}
+
+// DeclarativeFollow : a link to follow on the way to the download link
+type DeclarativeFollow struct {
+	Selector string `json:"selector" yaml:"selector"`
+	Attr     string `json:"attr" yaml:"attr"` // defaults to href
+}
+
+// DeclarativeEngine : An Engine described by a DeclarativeConfig
+type DeclarativeEngine struct {
+	Props
+	config    DeclarativeConfig
+	yearRegex *regexp.Regexp
+	sizeRegex *regexp.Regexp
+}
+
+// NewDeclarativeEngine : A Movie Engine Constructor for DeclarativeEngine
+func NewDeclarativeEngine(config DeclarativeConfig) (*DeclarativeEngine, error) {
+	if config.Name == "" {
+		return nil, errors.New("engine name is required")
+	}
+	if config.Selectors.Container == "" || config.Selectors.Item == "" {
+		return nil, fmt.Errorf("%s: container and item selectors are required", config.Name)
+	}
+	baseURL, err := url.Parse(config.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", config.Name, err)
+	}
+	if !baseURL.IsAbs() {
+		return nil, fmt.Errorf("%s: base_url %q must be absolute", config.Name, config.BaseURL)
+	}
+	if config.Selectors.LinkAttr == "" {
+		config.Selectors.LinkAttr = "href"
+	}
+	if config.Selectors.CoverAttr == "" {
+		config.Selectors.CoverAttr = "src"
+	}
+	for i := range config.Follow {
+		if config.Follow[i].Attr == "" {
+			config.Follow[i].Attr = "href"
+		}
+	}
+
+	engine := DeclarativeEngine{config: config}
+	if config.Selectors.YearRegex != "" {
+		if engine.yearRegex, err = regexp.Compile(config.Selectors.YearRegex); err != nil {
+			return nil, fmt.Errorf("%s: year_regex: %v", config.Name, err)
+		}
+	}
+	if config.Selectors.SizeRegex != "" {
+		if engine.sizeRegex, err = regexp.Compile(config.Selectors.SizeRegex); err != nil {
+			return nil, fmt.Errorf("%s: size_regex: %v", config.Name, err)
+		}
+	}
+	engine.Name = config.Name
+	engine.BaseURL = baseURL
+	engine.Description = config.Description
+	engine.SearchURL = baseURL.ResolveReference(&url.URL{Path: config.Search.Path})
+	engine.ListURL = baseURL.ResolveReference(&url.URL{Path: config.List.Path})
+	for _, raw := range config.Mirrors {
+		mirror, err := parseBaseURL(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: mirror: %v", config.Name, err)
+		}
+		engine.Mirrors = append(engine.Mirrors, mirror)
+	}
+	return &engine, nil
+}
+
+// Engine Interface Methods
+
+func (engine *DeclarativeEngine) String() string {
+	st := fmt.Sprintf("%s (%s)", engine.Name, engine.BaseURL)
+	return st
+}
+
+func (engine *DeclarativeEngine) GetParseAttrs(req *Request) (string, string, error) {
+	return engine.config.Selectors.Container, engine.config.Selectors.Item, nil
+}
+
+func (engine *DeclarativeEngine) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
+	selectors := engine.config.Selectors
+	movie := Movie{
+		Index:    index,
+		IsSeries: false,
+		Source:   engine.Name,
+	}
+	if selectors.Title == "" {
+		movie.Title = strings.TrimSpace(el.Text)
+	} else {
+		movie.Title = strings.TrimSpace(el.ChildText(selectors.Title))
+	}
+	if movie.Title == "" {
+		return movie, errors.New("no title found")
+	}
+	if engine.yearRegex != nil {
+		if match := engine.yearRegex.FindStringSubmatch(movie.Title); len(match) > 0 {
+			movie.Year, _ = strconv.Atoi(match[len(match)-1])
+		}
+	}
+	if selectors.Description != "" {
+		movie.Description = strings.TrimSpace(el.ChildText(selectors.Description))
+	}
+	size := el.Text
+	if selectors.Size != "" {
+		size = strings.TrimSpace(el.ChildText(selectors.Size))
+		movie.Size = size
+	}
+	if engine.sizeRegex != nil {
+		if match := engine.sizeRegex.FindStringSubmatch(size); len(match) > 0 {
+			movie.Size = strings.TrimSpace(match[len(match)-1])
+		}
+	}
+	if selectors.Cover != "" {
+		if cover := el.ChildAttr(selectors.Cover, selectors.CoverAttr); cover != "" {
+			movie.CoverPhotoLink = el.Request.AbsoluteURL(cover)
+		}
+	}
+
+	var link string
+	if selectors.Link == "" {
+		link = el.Attr(selectors.LinkAttr)
+	} else {
+		link = el.ChildAttr(selectors.Link, selectors.LinkAttr)
+	}
+	downloadLink, err := url.Parse(el.Request.AbsoluteURL(link))
+	if err != nil {
+		return movie, err
+	}
+	movie.DownloadLink = downloadLink
+	return movie, nil
+}
+
+func (engine *DeclarativeEngine) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
+	follow := engine.config.Follow
+	// Movies may share the pages of a step, which are then visited once for each of them
+	downloadCollector.AllowURLRevisit = len(follow) > 1
+	for i := range follow {
+		step := i
+		downloadCollector.OnHTML(follow[step].Selector, func(e *colly.HTMLElement) {
+			// Only the first match on a page of the current step is followed
+			current, _ := strconv.Atoi(e.Request.Ctx.Get("step"))
+			if current != step || e.Index != 0 {
+				return
+			}
+			movieIndex := GetMovieIndexFromCtx(e.Request)
+			movie := &(*movies)[movieIndex]
+			link := e.Attr(follow[step].Attr)
+			if link == "" {
+				return
+			}
+			downloadLink, err := url.Parse(e.Request.AbsoluteURL(link))
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			movie.DownloadLink = downloadLink
+			if step+1 < len(follow) {
+				// Keep track of the step and the movie in a new context so handlers of the next
+				// step do not fire on this page, and movies sharing the next page keep their chain
+				next := colly.NewContext()
+				next.Put("step", strconv.Itoa(step+1))
+				next.Put("movieIndex", strconv.Itoa(movieIndex))
+				downloadCollector.Request("GET", downloadLink.String(), nil, next, nil)
+			}
+		})
+	}
+}
+
+// expand : replace {query} and {page} in s
+func expand(s string, req *Request) string {
+	return strings.NewReplacer(
+		"{query}", req.Query,
+		"{page}", strconv.Itoa(req.Page),
+	).Replace(s)
+}
+
+// buildURL : set the path and params of the url of req from u
+func (engine *DeclarativeEngine) buildURL(req *Request, u DeclarativeURL) {
+	req.URL = engine.BaseURL.ResolveReference(&url.URL{Path: expand(u.Path, req)})
+	q := req.URL.Query()
+	for key, value := range u.Params {
+		q.Set(key, expand(value, req))
+	}
+	req.URL.RawQuery = q.Encode()
+}
+
+// List : list all the movies on a page
+func (engine *DeclarativeEngine) List(ctx context.Context, page int) (SearchResult, error) {
+	req := engine.NewListRequest(page)
+	result := SearchResult{
+		Query: "List of Recent Uploads - Page " + strconv.Itoa(page),
+	}
+	engine.buildURL(req, engine.config.List)
+	movies, err := Scrape(ctx, engine, req)
+	if err != nil {
+		return result, err
+	}
+	result.Movies = movies
+	return result, nil
+}
+
+// Search : Searches the engine for a particular query and return an array of movies
+func (engine *DeclarativeEngine) Search(ctx context.Context, param ...string) (SearchResult, error) {
+	query := param[0]
+	req := engine.NewSearchRequest(query)
+	result := SearchResult{
+		Query: query,
+	}
+	if len(param) > 1 {
+		req.Page, _ = strconv.Atoi(param[1])
+	}
+	engine.buildURL(req, engine.config.Search)
+	movies, err := Scrape(ctx, engine, req)
+	if err != nil {
+		return result, err
+	}
+	result.Movies = movies
+	return result, nil
+}
+
+// LoadDeclarativeConfig : read a DeclarativeConfig from a .yaml, .yml or .json file
+func LoadDeclarativeConfig(file string) (DeclarativeConfig, error) {
+	var config DeclarativeConfig
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return config, err
+	}
+	if strings.ToLower(filepath.Ext(file)) == ".json" {
+		err = json.Unmarshal(content, &config)
+	} else {
+		err = yaml.UnmarshalStrict(content, &config)
+	}
+	if err != nil {
+		return config, fmt.Errorf("%s: %v", file, err)
+	}
+	return config, nil
+}
+
+// LoadDeclarativeEngines : register an engine for every YAML or JSON file in dir
+// An engine with the name of an already registered engine replaces it, so that broken
+// built-in engines can be fixed from config. Invalid files are logged and skipped
+func LoadDeclarativeEngines(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		switch strings.ToLower(filepath.Ext(f.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		file := filepath.Join(dir, f.Name())
+		config, err := LoadDeclarativeConfig(file)
+		if err != nil {
+			log.Errorf("Could not load engine: %v", err)
+			continue
+		}
+		// Validate the config once instead of on every use of the engine
+		if _, err = NewDeclarativeEngine(config); err != nil {
+			log.Errorf("Could not load engine from %s: %v", file, err)
+			continue
+		}
+		log.Debugf("Loaded engine %s from %s", config.Name, file)
+		register(config.Name, func() Engine {
+			engine, _ := NewDeclarativeEngine(config)
+			return engine
+		}, true)
+	}
+	return nil
+}
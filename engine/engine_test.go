@@ -1,14 +1,30 @@
 package engine
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/go-phie/gophie/transport"
+	"github.com/gocolly/colly/v2"
+	"github.com/spf13/viper"
 )
 
 func testResults(t *testing.T, engine Engine) {
 	counter := map[string]int{}
-	var result SearchResult
 	var searchTerm string
 	fmt.Println(engine.String())
 	// different search terms on engines
@@ -23,9 +39,11 @@ func testResults(t *testing.T, engine Engine) {
 	default:
 		searchTerm = "jumanji"
 	}
-	result = engine.Search(searchTerm)
+	result, err := engine.Search(context.Background(), searchTerm)
 
-	if len(result.Movies) < 1 {
+	if err != nil {
+		t.Errorf("Search failed on %v: %v", engine.String(), err)
+	} else if len(result.Movies) < 1 {
 		t.Errorf("No movies returned from %v", engine.String())
 	} else {
 		for _, movie := range result.Movies {
@@ -54,3 +72,702 @@ func TestEngines(t *testing.T) {
 		}
 	}
 }
+
+func TestScrapeErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		kind    error
+	}{
+		{"cloudflare", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Server", "cloudflare")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, ErrBlockedByCloudflare},
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}, ErrEngineUnreachable},
+		{"layout changed", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html><body><p>Maintenance</p></body></html>")
+		}, ErrLayoutChanged},
+		{"no results", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><main><p>No results</p></main></body></html>`)
+		}, ErrNoResults},
+	}
+	for _, c := range cases {
+		ts := httptest.NewServer(c.handler)
+		engine := NewNetNaijaEngine()
+		engine.SearchURL, _ = url.Parse(ts.URL + "/search")
+		_, err := engine.Search(context.Background(), "jumanji")
+		if !errors.Is(err, c.kind) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.kind, err)
+		}
+		ts.Close()
+	}
+}
+
+func TestScrapeCancellation(t *testing.T) {
+	// A site that never responds
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	engine := NewNetNaijaEngine()
+	engine.SearchURL, _ = url.Parse(ts.URL + "/search")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := engine.Search(ctx, "jumanji")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Search was not cancelled, took %v", elapsed)
+	}
+}
+
+func TestConcurrentSearch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		// Echo the query back as the title of a single result
+		fmt.Fprintf(w, `<html><body><main><article class="sr-one"><h3>%s</h3><a href="/detail"></a></article></main></body></html>`,
+			r.URL.Query().Get("t"))
+	}))
+	defer ts.Close()
+
+	engine := NewNetNaijaEngine()
+	engine.SearchURL, _ = url.Parse(ts.URL + "/search")
+	searchURL := engine.SearchURL.String()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(query string) {
+			defer wg.Done()
+			result, err := engine.Search(context.Background(), query)
+			if err != nil {
+				t.Errorf("Search failed for %s: %v", query, err)
+				return
+			}
+			if len(result.Movies) != 1 || result.Movies[0].Title != query {
+				t.Errorf("Expected a single result for %s, got %v", query, result.Titles())
+			}
+		}(fmt.Sprintf("query %d", i))
+	}
+	wg.Wait()
+
+	if engine.SearchURL.String() != searchURL {
+		t.Errorf("SearchURL was modified to %s", engine.SearchURL)
+	}
+}
+
+func TestSearchAll(t *testing.T) {
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><main><article class="sr-one"><h3>Jumanji (2017)</h3><a href="/detail"></a></article></main></body></html>`)
+	}))
+	defer working.Close()
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hanging.Close()
+
+	engines := map[string]Engine{}
+	for name, server := range map[string]*httptest.Server{"a": working, "b": hanging, "c": working} {
+		e := NewNetNaijaEngine()
+		e.SearchURL, _ = url.Parse(server.URL + "/search")
+		engines[name] = e
+	}
+
+	result, err := SearchAll(context.Background(), engines, 200*time.Millisecond, "jumanji")
+	if err != nil {
+		t.Fatalf("SearchAll failed: %v", err)
+	}
+	if len(result.Movies) != 2 {
+		t.Errorf("Expected 2 movies, got %d", len(result.Movies))
+	}
+	for i, movie := range result.Movies {
+		if movie.Index != i || movie.Source != "NetNaija" {
+			t.Errorf("Unexpected index or source for %v", movie)
+		}
+	}
+	if len(result.Groups) != 1 || len(result.Groups[0].Movies) != 2 {
+		t.Errorf("Expected the movies to be grouped, got %v", result.Groups)
+	}
+	if len(result.Failed) != 1 || !errors.Is(result.Failed["b"], context.DeadlineExceeded) {
+		t.Errorf("Expected engine b to time out, got %v", result.Failed)
+	}
+}
+
+func TestSearchAllWithoutMovies(t *testing.T) {
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><main></main></body></html>`)
+	}))
+	defer empty.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+	}))
+	defer down.Close()
+	search := func(servers map[string]*httptest.Server) (FederatedResult, error) {
+		engines := map[string]Engine{}
+		for name, server := range servers {
+			e := NewNetNaijaEngine()
+			e.SearchURL, _ = url.Parse(server.URL + "/search")
+			engines[name] = e
+		}
+		return SearchAll(context.Background(), engines, time.Second, "jumanji")
+	}
+
+	result, err := search(map[string]*httptest.Server{"a": empty, "b": down})
+	if !errors.Is(err, ErrNoResults) || len(result.Failed) != 1 || result.Failed["b"] == nil {
+		t.Errorf("Expected no results with only b failing, got %v and %v", err, result.Failed)
+	}
+	if _, err = SearchAll(context.Background(), map[string]Engine{"a": NewNetNaijaEngine()}, time.Second); err == nil {
+		t.Error("Expected an error without a query")
+	}
+	result, err = search(map[string]*httptest.Server{"a": down, "b": down})
+	if !errors.Is(err, ErrEngineUnreachable) || len(result.Failed) != 2 {
+		t.Errorf("Expected every engine to fail, got %v and %v", err, result.Failed)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	result := SearchResult{
+		Query: "jumanji",
+		Movies: []Movie{
+			{Title: "Jumanji: The Next Level (2019)", Source: "NetNaija"},
+			{Title: "Jumanji (2017)", Source: "FzMovies"},
+			{Title: "Jumanji: Welcome to the Jungle [2017] 720p", Source: "Nkiri"},
+			{Title: "Jumanji: The Next Level (2019) [HD]", Source: "FzMovies"},
+			{Title: "Jumanji (1995)", Source: "NetNaija"},
+			{Title: "Zathura 2005", Source: "CoolMoviez"},
+		},
+	}
+	groups := result.Group()
+	if len(groups) != 4 {
+		t.Fatalf("Expected 4 groups, got %d", len(groups))
+	}
+	expected := []struct {
+		title   string
+		year    int
+		sources int
+	}{
+		{"Jumanji", 1995, 1},
+		{"Jumanji: The Next Level", 2019, 2},
+		{"Jumanji: Welcome to the Jungle", 2017, 2},
+		{"Zathura", 2005, 1},
+	}
+	for i, e := range expected {
+		if groups[i].Title != e.title || groups[i].Year != e.year || len(groups[i].Sources()) != e.sources {
+			t.Errorf("Expected group %d to be %v, got %s (%d) from %v", i, e, groups[i].Title, groups[i].Year, groups[i].Sources())
+		}
+	}
+	if groups[2].Quality != "720p" {
+		t.Errorf("Expected quality 720p, got %s", groups[2].Quality)
+	}
+}
+
+// An engine implemented only with the exported API as a separate module would
+type customEngine struct {
+	Props
+}
+
+func (e *customEngine) String() string { return e.Name }
+
+func (e *customEngine) Search(ctx context.Context, param ...string) (SearchResult, error) {
+	movies, err := Scrape(ctx, e, e.NewSearchRequest(param[0]))
+	return SearchResult{Query: param[0], Movies: movies}, err
+}
+
+func (e *customEngine) List(ctx context.Context, page int) (SearchResult, error) {
+	movies, err := Scrape(ctx, e, e.NewListRequest(page))
+	return SearchResult{Movies: movies}, err
+}
+
+func (e *customEngine) GetParseAttrs(req *Request) (string, string, error) {
+	return "ul", "li", nil
+}
+
+func (e *customEngine) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
+	link, err := url.Parse(el.Request.AbsoluteURL(el.ChildAttr("a", "href")))
+	return Movie{Index: index, Title: el.Text, Source: e.Name, DownloadLink: link}, err
+}
+
+func (e *customEngine) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
+}
+
+func TestRegister(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><ul><li><a href="/jumanji.mp4">Jumanji</a></li></ul></body></html>`)
+	}))
+	defer ts.Close()
+
+	Register("Custom", func() Engine {
+		e := &customEngine{}
+		e.Name = "Custom"
+		e.SearchURL, _ = url.Parse(ts.URL + "/search")
+		return e
+	})
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "custom")
+		registryMu.Unlock()
+	}()
+
+	if !containsString(EngineNames(), "custom") {
+		t.Errorf("custom not in registered engines %v", EngineNames())
+	}
+	e, err := GetEngine("CUSTOM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := e.Search(context.Background(), "jumanji")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Movies) != 1 || result.Movies[0].Title != "Jumanji" || result.Movies[0].Source != "Custom" {
+		t.Errorf("Unexpected result %v", result.Movies)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering an engine twice to panic")
+		}
+	}()
+	Register("custom", func() Engine { return &customEngine{} })
+}
+
+func TestDeclarativeEngine(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><main>
+			<article><h3>%s (2017)</h3><a href="/movie/1">Details</a><span>Size: 700 MB</span></article>
+			<article><h3>%[1]s (2017) 720p</h3><a href="/movie/2">Details</a><span>Size: 400 MB</span></article>
+		</main></body></html>`, r.URL.Query().Get("q"))
+	})
+	// Both movies lead to the same download page
+	for _, path := range []string{"/movie/1", "/movie/2"} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><a class="dl" href="/download/1">Download</a></body></html>`)
+		})
+	}
+	mux.HandleFunc("/download/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><a class="dl" href="/files/jumanji.mp4">Start</a></body></html>`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	config := fmt.Sprintf(`name: Declared
+base_url: %s
+search:
+  path: /search
+  params:
+    q: "{query}"
+selectors:
+  container: main
+  item: article
+  title: h3
+  link: a
+  year_regex: '\((\d{4})\)'
+  size_regex: 'Size: (.*)'
+follow:
+  - selector: a.dl
+  - selector: a.dl
+`, ts.URL)
+	if err := ioutil.WriteFile(filepath.Join(dir, "declared.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Invalid files are skipped
+	if err := ioutil.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"name": "broken"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDeclarativeEngines(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "declared")
+		registryMu.Unlock()
+	}()
+	if _, err := GetEngine("broken"); err == nil {
+		t.Error("Expected invalid engine not to be registered")
+	}
+
+	e, err := GetEngine("declared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := e.Search(context.Background(), "Jumanji")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Movies) != 2 {
+		t.Fatalf("Expected 2 movies, got %v", result.Movies)
+	}
+	movie := result.Movies[0]
+	if movie.Title != "Jumanji (2017)" || movie.Year != 2017 || movie.Size != "700 MB" {
+		t.Errorf("Unexpected movie %#v", movie)
+	}
+	for _, movie := range result.Movies {
+		if expected := ts.URL + "/files/jumanji.mp4"; movie.DownloadLink.String() != expected {
+			t.Errorf("Expected download link %s for %s, got %s", expected, movie.Title, movie.DownloadLink)
+		}
+	}
+}
+
+var update = flag.Bool("update", false, "update the results of engine fixtures with the current results")
+
+// compareFixtureResult : compare result to the JSON recorded in file, or record it with -update
+func compareFixtureResult(t *testing.T, file string, result SearchResult) {
+	if *update {
+		if err := writeFixtureResult(file, result); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(expected) != string(got)+"\n" {
+		t.Errorf("Results differ from %s, got\n%s", file, got)
+	}
+}
+
+// Replay the pages recorded for every built-in engine with `gophie engines record`
+func TestEngineFixtures(t *testing.T) {
+	defer func(previous http.RoundTripper) { Transport = previous }(Transport)
+	for _, name := range EngineNames() {
+		dir := filepath.Join("testdata", "fixtures", name)
+		t.Run(name, func(t *testing.T) {
+			query, err := FixtureQuery(dir)
+			if err != nil {
+				t.Fatalf("No fixtures recorded for %s: %v", name, err)
+			}
+
+			Transport = transport.NewReplayTransport(filepath.Join(dir, "pages"))
+			e, err := GetEngine(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := e.Search(context.Background(), query)
+			if err != nil {
+				t.Errorf("Search failed: %v", err)
+			}
+			compareFixtureResult(t, filepath.Join(dir, "search.json"), result)
+
+			result, err = e.List(context.Background(), 1)
+			if err != nil {
+				t.Errorf("List failed: %v", err)
+			}
+			compareFixtureResult(t, filepath.Join(dir, "list.json"), result)
+		})
+	}
+}
+
+func TestRecordFixtures(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><ul><li><a href="/%s.mp4">%s</a></li></ul></body></html>`, r.URL.Path, r.URL.Path)
+	}))
+	e := &customEngine{}
+	e.Name = "Custom"
+	e.SearchURL, _ = url.Parse(ts.URL + "/search")
+	e.ListURL, _ = url.Parse(ts.URL + "/list")
+
+	dir := t.TempDir()
+	if err := RecordFixtures(context.Background(), e, dir, "jumanji"); err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+	if query, err := FixtureQuery(dir); query != "jumanji" || err != nil {
+		t.Errorf("Expected recorded query jumanji, got %q %v", query, err)
+	}
+
+	// The recorded pages are served once the site is gone
+	defer func(previous http.RoundTripper) { Transport = previous }(Transport)
+	Transport = transport.NewReplayTransport(filepath.Join(dir, "pages"))
+	result, err := e.List(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareFixtureResult(t, filepath.Join(dir, "list.json"), result)
+}
+
+func TestCheckEngine(t *testing.T) {
+	defer func(previous http.RoundTripper) { Transport = previous }(Transport)
+	Transport = transport.NewReplayTransport(filepath.Join("testdata", "fixtures", "fzmovies", "pages"))
+	health := CheckEngine(context.Background(), "fzmovies", NewFzEngine(), CanaryQuery("fzmovies"))
+	if health.Status != HealthOK || health.Search.Resolved != 2 || health.List.Resolved != 1 {
+		t.Errorf("Expected fzmovies to be healthy, got %+v", health)
+	}
+	Transport = http.DefaultTransport
+
+	// Links to pages that are not media files
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/list" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><ul><li><a href="/jumanji">Jumanji</a></li></ul></body></html>`)
+	}))
+	defer ts.Close()
+	e := &customEngine{}
+	e.Name = "Custom"
+	e.SearchURL, _ = url.Parse(ts.URL + "/search")
+	e.ListURL, _ = url.Parse(ts.URL + "/list")
+	health = CheckEngine(context.Background(), "custom", e, "jumanji")
+	if health.Search.Status != HealthDegraded || health.Search.Checked != 1 {
+		t.Errorf("Expected search to be degraded, got %+v", health.Search)
+	}
+	if health.List.Status != HealthDown || !errors.Is(health.List.Err, ErrEngineUnreachable) {
+		t.Errorf("Expected list to be down, got %+v", health.List)
+	}
+	if health.Status != HealthDown {
+		t.Errorf("Expected custom to be down, got %s", health.Status)
+	}
+
+	b, err := json.Marshal(&health)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		List struct{ Status, Error string }
+	}
+	if err = json.Unmarshal(b, &decoded); err != nil || decoded.List.Error == "" {
+		t.Errorf("Expected error in JSON, got %s", b)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	os.Setenv("GOPHIE_FZMOVIES_BASE_URL", "https://fzmovies.example/")
+	os.Setenv("GOPHIE_FZMOVIES_MIRRORS", "https://mirror1.example/, https://mirror2.example/")
+	defer os.Unsetenv("GOPHIE_FZMOVIES_BASE_URL")
+	defer os.Unsetenv("GOPHIE_FZMOVIES_MIRRORS")
+	viper.Set("engines.tvseries.list_url", "https://tv.example/latest.php")
+	defer viper.Set("engines.tvseries.list_url", "")
+
+	e, err := GetEngine("FzMovies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := e.(*FzEngine).Props
+	if props.BaseURL.String() != "https://fzmovies.example/" {
+		t.Errorf("Expected base url from environment, got %v", props.BaseURL)
+	}
+	if props.SearchURL.String() != "https://fzmovies.example/csearch.php" {
+		t.Errorf("Expected search url on the new base url, got %v", props.SearchURL)
+	}
+	if len(props.Mirrors) != 2 || props.Mirrors[1].Host != "mirror2.example" {
+		t.Errorf("Expected mirrors from environment, got %v", props.Mirrors)
+	}
+
+	e, err = GetEngine("tvseries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	props = e.(*TvSeriesEngine).Props
+	if props.ListURL.String() != "https://tv.example/latest.php" || props.BaseURL.Host != "tvseries.in" {
+		t.Errorf("Expected only the list url to be overridden, got %v and %v", props.ListURL, props.BaseURL)
+	}
+
+	// Engines are configured concurrently by searches of all engines
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			GetEngines()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestScrapeMirrors(t *testing.T) {
+	defer func(previous http.RoundTripper) { Transport = previous }(Transport)
+	// Only pages of www.fzmovies.net were recorded, so the moved domain cannot be reached
+	Transport = transport.NewReplayTransport(filepath.Join("testdata", "fixtures", "fzmovies", "pages"))
+	e := NewFzEngine()
+	e.BaseURL, _ = url.Parse("https://fzmovies.invalid/")
+	e.SearchURL = rebase(e.SearchURL, e.BaseURL)
+	e.ListURL = rebase(e.ListURL, e.BaseURL)
+
+	if _, err := e.Search(context.Background(), "jumanji"); !errors.Is(err, ErrEngineUnreachable) {
+		t.Fatalf("Expected ErrEngineUnreachable without mirrors, got %v", err)
+	}
+	mirror, _ := url.Parse("https://www.fzmovies.net/")
+	e.Mirrors = []*url.URL{mirror}
+	result, err := e.Search(context.Background(), "jumanji")
+	if err != nil {
+		t.Fatalf("Expected search to fall back to mirror: %v", err)
+	}
+	compareFixtureResult(t, filepath.Join("testdata", "fixtures", "fzmovies", "search.json"), result)
+}
+
+func TestResolveDownloadLink(t *testing.T) {
+	defer func(previous http.RoundTripper) { Transport = previous }(Transport)
+	Transport = transport.NewReplayTransport(filepath.Join("testdata", "fixtures", "fzmovies", "pages"))
+	e := NewFzEngine()
+	result, err := e.Search(context.Background(), "jumanji")
+	if err != nil {
+		t.Fatal(err)
+	}
+	movie := result.Movies[0]
+	if movie.DetailLink == nil || movie.DetailLink.String() == movie.DownloadLink.String() {
+		t.Fatalf("Expected detail link to be kept, got %v", movie.DetailLink)
+	}
+
+	expired := movie
+	expired.DownloadLink, _ = url.Parse("https://d2.fzmovies.net/files/expired.mp4")
+	resolved, err := ResolveDownloadLink(context.Background(), e, expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.DownloadLink.String() != movie.DownloadLink.String() {
+		t.Errorf("Expected download link %v to be resolved again, got %v", movie.DownloadLink, resolved.DownloadLink)
+	}
+
+	expired.DetailLink = nil
+	if _, err = ResolveDownloadLink(context.Background(), e, expired); err == nil {
+		t.Error("Expected error without a detail link")
+	}
+}
+
+func TestWithMovieFound(t *testing.T) {
+	defer func(previous http.RoundTripper) { Transport = previous }(Transport)
+	Transport = transport.NewReplayTransport(filepath.Join("testdata", "fixtures", "fzmovies", "pages"))
+	var found []Movie
+	ctx := WithMovieFound(context.Background(), func(movie Movie) { found = append(found, movie) })
+	result, err := NewFzEngine().Search(ctx, "jumanji")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(result.Movies) {
+		t.Fatalf("Expected every movie to be found, got %d of %d", len(found), len(result.Movies))
+	}
+	for i, movie := range found {
+		if movie.Title != result.Movies[i].Title || movie.DownloadLink.String() != result.Movies[i].DownloadLink.String() {
+			t.Errorf("Expected %s to be found with its download link %v, got %v",
+				result.Movies[i].Title, result.Movies[i].DownloadLink, movie.DownloadLink)
+		}
+	}
+}
+
+func TestEpisodes(t *testing.T) {
+	link, _ := url.Parse("https://example.com/episode.mkv")
+	cases := []struct {
+		series string
+		titles []string
+		codes  []string
+	}{
+		{"Shingeki no Kyojin S3", []string{
+			"[AnimeOut] Shingeki no Kyojin S3 - 02 [1080p]",
+			"[AnimeOut] Shingeki no Kyojin S3 - 01 [1080p]",
+		}, []string{"S03E01", "S03E02"}},
+		{"Flower of Evil", []string{
+			"Flower of Evil Episode 10", "Flower of Evil Episode 2", "Flower of Evil Episode 1",
+		}, []string{"S01E01", "S01E02", "S01E10"}},
+		{"Attack on Titan", []string{"Attack.on.Titan.S03E02.720p.mkv", "Attack.on.Titan.S03E01.720p.mkv"},
+			[]string{"S03E01", "S03E02"}},
+		// Links listed by their position on the page
+		{"The Boys Season 2", []string{"2", "0", "1"}, []string{"S02E01", "S02E02", "S02E03"}},
+		// Episodes without a number keep the numbered episodes in place
+		{"Flower of Evil", []string{
+			"Flower of Evil Special", "Flower of Evil Episode 2", "Flower of Evil Episode 1",
+			"Flower of Evil Episode 3", "Flower of Evil Behind the Scenes",
+		}, []string{"S01E01", "S01E02", "S01E03", "S01E04", "S01E05"}},
+	}
+	for _, c := range cases {
+		movie := Movie{Title: c.series, SDownloadLink: map[string]*url.URL{}}
+		for _, title := range c.titles {
+			movie.SDownloadLink[title] = link
+		}
+		var codes []string
+		for _, episode := range movie.Episodes() {
+			codes = append(codes, episode.Code())
+		}
+		if !reflect.DeepEqual(codes, c.codes) {
+			t.Errorf("%s: expected episodes %v, got %v", c.series, c.codes, codes)
+		}
+	}
+
+	// Seasons filled by engines are exposed in order
+	movie := Movie{Title: "The Boys", DownloadLink: link, Seasons: GroupSeasons([]Episode{
+		{Season: 2, Number: 1, Title: "Episode 1", Link: link},
+		{Season: 1, Number: 2, Title: "Episode 2", Link: link},
+		{Season: 1, Number: 1, Title: "Episode 1", Link: link, Subtitle: link},
+	})}
+	content, err := json.Marshal(&movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Seasons []struct {
+			Number   int
+			Episodes []struct {
+				Number   int
+				Link     string
+				Subtitle string
+			}
+		}
+	}
+	if err = json.Unmarshal(content, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Seasons) != 2 || decoded.Seasons[0].Number != 1 || len(decoded.Seasons[0].Episodes) != 2 ||
+		decoded.Seasons[0].Episodes[0].Number != 1 || decoded.Seasons[0].Episodes[0].Subtitle != link.String() ||
+		decoded.Seasons[1].Episodes[0].Link != link.String() {
+		t.Errorf("Expected seasons and episodes in order, got %s", content)
+	}
+}
+
+func TestSelectVariant(t *testing.T) {
+	link := func(name string) *url.URL {
+		u, _ := url.Parse("https://example.com/" + name)
+		return u
+	}
+	variants := []Variant{
+		NewVariant("480p", "350 MB", link("movie.480p.mp4")),
+		NewVariant("1080p", "1.8 GB", link("movie.1080p.mkv")),
+		NewVariant("720p", "812 MB", link("movie.720p.mkv")),
+	}
+	cases := []struct {
+		quality string
+		maxSize string
+		want    string
+	}{
+		{"", "", "1080p"},
+		{"720p", "", "720p"},
+		{"720P", "", "720p"},
+		{"", "1GB", "720p"},
+		{"1080p", "1GB", "720p"},
+		{"900p", "", "720p"},
+		{"360p", "", "480p"},
+	}
+	for _, c := range cases {
+		variant, err := SelectVariant(variants, c.quality, ParseSize(c.maxSize))
+		if err != nil || variant.Quality != c.want {
+			t.Errorf("quality %q and max size %q: expected %s, got %v (%v)", c.quality, c.maxSize, c.want, variant, err)
+		}
+	}
+	if _, err := SelectVariant(variants, "", ParseSize("100MB")); err == nil {
+		t.Error("Expected an error when every variant is too large")
+	}
+	if variants[1].Container != "mkv" || ParseSize("(1.5 GB)") != 1610612736 || ParseSize("---MB") != 0 {
+		t.Errorf("Unexpected container %q or sizes", variants[1].Container)
+	}
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,8 +19,9 @@ import (
 // Mode : The mode of operation for scraping
 type Mode int
 
-// Dynamically assign Selenium URL
-var ()
+// Transport : the transport every request of the engines is made with
+// It is replaced to record or replay the pages of engines in tests
+var Transport http.RoundTripper = http.DefaultTransport
 
 const (
 	// SearchMode : in this mode a query is searched for
@@ -33,38 +35,76 @@ func (m Mode) String() string {
 }
 
 // Engine : interface for all engines
+// Engines embed Props to get GetName, NewSearchRequest and NewListRequest, and usually
+// implement Search and List by building a Request and passing it to Scrape.
+// Engines can be implemented outside this package and added with Register
 type Engine interface {
-	getName() string
-	getParseURL() *url.URL
-	Search(param ...string) SearchResult
-	List(page int) SearchResult
+	GetName() string
+	Search(ctx context.Context, param ...string) (SearchResult, error)
+	List(ctx context.Context, page int) (SearchResult, error)
 	String() string
 
-	// parseSingleMovie: parses the result of a colly HTMLElement and returns a movie
-	// The input el is usually the block of code from the article specified in getParseAttrs
-	parseSingleMovie(el *colly.HTMLElement, index int) (Movie, error)
+	// ParseSingleMovie : parses the result of a colly HTMLElement and returns a movie
+	// The input el is usually the block of code from the article specified in GetParseAttrs
+	ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error)
 
-	// getParseAttrs : get the attributes to use to parse a returned soup
+	// GetParseAttrs : get the attributes to use to parse a returned soup
 	// the first return string is the part of the html to be parsed e.g `body`, `main`
 	// the second return string is the attributes to be used in parsing the element specified
 	// by the first return. For example returning main, article.sr-one results in parsing the main
 	// tag and parsing all article.sr-one within the main html
-	getParseAttrs() (string, string, error)
+	GetParseAttrs(req *Request) (string, string, error)
 
-	// parseSingleMovie: parses the result of a colly HTMLElement and returns a movie
-	updateDownloadProps(downloadCollector *colly.Collector, movies *[]Movie)
+	// UpdateDownloadProps : registers callbacks on the download collector that visit the
+	// detail pages of the movies and update their download links
+	UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie)
 }
 
-// Scrape : Parse queries a url and return results
-func Scrape(engine Engine) ([]Movie, error) {
+// Scrape : Parse queries the url of req and return results
+// All requests made while scraping are cancelled once ctx is done.
+// When the site cannot be reached the page is requested from each mirror of the engine in turn
+func Scrape(ctx context.Context, engine Engine, req *Request) ([]Movie, error) {
+	movies, err := scrape(ctx, engine, req)
+	if err == nil || !shouldTryMirror(err) {
+		return movies, err
+	}
+	// Fall back through the mirrors of the engine in order
+	for _, mirror := range mirrorsOf(engine) {
+		if ctx.Err() != nil {
+			break
+		}
+		mirrorReq := *req
+		mirrorReq.URL = rebase(req.URL, mirror)
+		log.Infof("%s could not be reached, trying mirror %s", engine.GetName(), mirror.Host)
+		movies, mirrorErr := scrape(ctx, engine, &mirrorReq)
+		if mirrorErr == nil || !shouldTryMirror(mirrorErr) {
+			return movies, mirrorErr
+		}
+		log.Debugf("Mirror %s failed: %v", mirror.Host, mirrorErr)
+	}
+	return nil, err
+}
+
+// movieFoundKey : the key of the function called with every movie found in a context
+type movieFoundKey struct{}
+
+// WithMovieFound : a context whose searches and listings call found with every movie as soon as its
+// detail pages are scraped, rather than once the whole page is. found is called from the goroutines
+// of the engines, which may be several when all engines are searched at once
+func WithMovieFound(ctx context.Context, found func(movie Movie)) context.Context {
+	return context.WithValue(ctx, movieFoundKey{}, found)
+}
+
+// newCollector : a collector for the pages of engine whose requests are cancelled once ctx is done
+// Pages are cached unless ignoreCache is set. The returned function releases the resources of the collector
+func newCollector(ctx context.Context, engine Engine, ignoreCache bool) (*colly.Collector, func(), error) {
 	// Config Vars
 	//  seleniumURL := fmt.Sprintf("%s/wd/hub", viper.GetString("selenium-url"))
 	cacheDir := viper.GetString("cache-dir")
-	ignoreCache := viper.GetBool("ignore-cache")
 	var (
-		t   *transport.ChromeDpTransport
-		err error
-		c   *colly.Collector
+		c        *colly.Collector
+		upstream = Transport
+		cleanup  = func() {}
 	)
 
 	if ignoreCache {
@@ -80,55 +120,133 @@ func Scrape(engine Engine) ([]Movie, error) {
 
 	useChromeDriver := viper.GetBool("use-chrome-driver")
 	// Add Cloud Flare scraper bypasser
-	if useChromeDriver && engine.getName() == "NetNaija" {
+	if useChromeDriver && engine.GetName() == "NetNaija" {
 		log.Debug("Switching to ChromeDpTransport")
-		t, err = transport.NewChromeDpTransport(http.DefaultTransport)
+		t, err := transport.NewChromeDpTransport(Transport)
 		if err != nil {
-			log.Fatal(err)
+			return nil, cleanup, err
 		}
 
-		c.WithTransport(t)
-	}
-	// Close the WebDriver Instance
-	defer func() {
-		if useChromeDriver && engine.getName() == "NetNaija" {
+		upstream = t
+		// Close the WebDriver Instance
+		cleanup = func() {
 			t.RemoteAllocCancel()
 			t.Cancel()
 		}
-	}()
+	}
+	// Bind all requests to ctx. Clones share the transport of the collector
+	c.WithTransport(transport.NewContextTransport(ctx, upstream))
+	return c, cleanup, nil
+}
 
-	// Another collector for download Links
+// newDownloadLinkCollector : a clone of c that visits the detail pages of movies and
+// lets the engine update their download links
+func newDownloadLinkCollector(ctx context.Context, engine Engine, c *colly.Collector, movies *[]Movie) *colly.Collector {
 	downloadLinkCollector := c.Clone()
 
+	// Any Extras setup for downloads using can be specified in the function
+	engine.UpdateDownloadProps(ctx, downloadLinkCollector, movies)
+
+	// Attach Movie Index to Context before making visits
+	// Adding Movie Index to context ensures we can fetch a reference to the
+	// movie details when we need it
+	downloadLinkCollector.OnRequest(func(r *colly.Request) {
+		if ctx.Err() != nil {
+			r.Abort()
+			return
+		}
+		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml")
+		// Requests of engines that follow links already know their movie
+		if r.Ctx.Get("movieIndex") != "" {
+			return
+		}
+		for i, movie := range *movies {
+			if movie.DownloadLink.String() == r.URL.String() {
+				log.Debugf("Retrieving Download Link %v\n", movie.DownloadLink)
+				r.Ctx.Put("movieIndex", strconv.Itoa(i))
+			}
+		}
+		// Requests that cannot be traced back to a movie have nothing to update
+		if r.Ctx.Get("movieIndex") == "" {
+			log.Debugf("No movie found for %v. Aborting request", r.URL)
+			r.Abort()
+		}
+	})
+
+	// If Response Content Type is not Text, Abort the Request to prevent fully downloading the
+	// body in case of other types like mp4
+	downloadLinkCollector.OnResponseHeaders(func(r *colly.Response) {
+		if !strings.Contains(r.Headers.Get("Content-Type"), "text") {
+			r.Request.Abort()
+			log.Debugf("Response %s is not text/html. Aborting request", r.Request.URL)
+		}
+	})
+
+	downloadLinkCollector.OnResponse(func(r *colly.Response) {
+		movie := &(*movies)[GetMovieIndexFromCtx(r.Request)]
+		log.Debugf("Retrieved Download Link %v\n", movie.DownloadLink)
+	})
+
+	downloadLinkCollector.OnError(func(r *colly.Response, err error) {
+		log.Debugf("Could not retrieve %v: %v", r.Request.URL, err)
+	})
+	return downloadLinkCollector
+}
+
+// scrape : scrape the page of req once
+func scrape(ctx context.Context, engine Engine, req *Request) ([]Movie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, NewEngineError(engine.GetName(), ErrEngineUnreachable, err)
+	}
+	c, cleanup, err := newCollector(ctx, engine, viper.GetBool("ignore-cache"))
+	defer cleanup()
+	if err != nil {
+		return nil, NewEngineError(engine.GetName(), ErrEngineUnreachable, err)
+	}
+
 	movieIndex := 0
 	var movies []Movie
+	found, _ := ctx.Value(movieFoundKey{}).(func(movie Movie))
 
-	// Any Extras setup for downloads using can be specified in the function
-	engine.updateDownloadProps(downloadLinkCollector, &movies)
+	// Another collector for download Links
+	downloadLinkCollector := newDownloadLinkCollector(ctx, engine, c, &movies)
 
-	main, article, err := engine.getParseAttrs()
+	main, article, err := engine.GetParseAttrs(req)
 	if err != nil {
-		log.Fatal(err)
+		return nil, NewEngineError(engine.GetName(), ErrLayoutChanged, err)
 	}
 
 	//  c.OnHTML("div", func(e *colly.HTMLElement) {
 	//    log.Debugf("%#v", e)
 	//  })
 
+	// Track whether the page had the expected layout at all
+	foundMain := false
 	c.OnHTML(main, func(e *colly.HTMLElement) {
+		foundMain = true
 		e.ForEach(article, func(_ int, el *colly.HTMLElement) {
-			movie, err := engine.parseSingleMovie(el, movieIndex)
+			movie, err := engine.ParseSingleMovie(req, el, movieIndex)
 			if err != nil {
-				log.Errorf("%v could not be parsed", movie)
+				log.Errorf("%v could not be parsed: %v", movie, err)
 			} else {
+				// The page the download link is resolved from, to resolve it again once it expires
+				movie.DetailLink = copyURL(movie.DownloadLink)
 				movies = append(movies, movie)
 				downloadLinkCollector.Visit(movie.DownloadLink.String())
+				// Pages are visited in turn so the download links of the movie are resolved by now
+				if found != nil && ctx.Err() == nil {
+					found(movies[movieIndex])
+				}
 				movieIndex++
 			}
 		})
 	})
 
 	c.OnRequest(func(r *colly.Request) {
+		if ctx.Err() != nil {
+			r.Abort()
+			return
+		}
 		r.Headers.Set("Accept", "text/html")
 		log.Debugf("Visiting %v", r.URL.String())
 	})
@@ -137,34 +255,68 @@ func Scrape(engine Engine) ([]Movie, error) {
 		log.Debugf("Done %v", r.Request.URL.String())
 	})
 
-	// Attach Movie Index to Context before making visits
-	// Adding Movie Index to context ensures we can fetch a reference to the
-	// movie details when we need it
-	downloadLinkCollector.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml")
-		for i, movie := range movies {
-			if movie.DownloadLink.String() == r.URL.String() {
-				log.Debugf("Retrieving Download Link %v\n", movie.DownloadLink)
-				r.Ctx.Put("movieIndex", strconv.Itoa(i))
-			}
-		}
+	var scrapeErr error
+	c.OnError(func(r *colly.Response, err error) {
+		scrapeErr = classifyRequestError(engine.GetName(), r, err)
 	})
 
-	// If Response Content Type is not Text, Abort the Request to prevent fully downloading the
-	// body in case of other types like mp4
-	downloadLinkCollector.OnResponseHeaders(func(r *colly.Response) {
-		if !strings.Contains(r.Headers.Get("Content-Type"), "text") {
-			r.Request.Abort()
-			log.Debugf("Response %s is not text/html. Aborting request", r.Request.URL)
-		}
-	})
+	if err = c.Visit(req.URL.String()); err != nil && scrapeErr == nil {
+		scrapeErr = classifyRequestError(engine.GetName(), nil, err)
+	}
+	// Cancellation takes precedence over the errors of the aborted requests
+	if err = ctx.Err(); err != nil {
+		return nil, NewEngineError(engine.GetName(), ErrEngineUnreachable, err)
+	}
+	if scrapeErr != nil {
+		return nil, scrapeErr
+	}
+	if !foundMain {
+		return nil, NewEngineError(engine.GetName(), ErrLayoutChanged,
+			fmt.Errorf("%s not found on %s", main, req.URL))
+	}
+	if len(movies) == 0 {
+		return nil, NewEngineError(engine.GetName(), ErrNoResults, nil)
+	}
+	return movies, nil
+}
 
-	downloadLinkCollector.OnResponse(func(r *colly.Response) {
-		movie := &movies[getMovieIndexFromCtx(r.Request)]
-		log.Debugf("Retrieved Download Link %v\n", movie.DownloadLink)
+// ResolveDownloadLink : visit the detail page of movie on engine again to get fresh download links,
+// e.g once time limited links have expired. movie.DetailLink must be set
+func ResolveDownloadLink(ctx context.Context, engine Engine, movie Movie) (Movie, error) {
+	if movie.DetailLink == nil {
+		return movie, NewEngineError(engine.GetName(), ErrNoResults, fmt.Errorf("%s has no detail link", movie.Title))
+	}
+	// Cached pages would give back the expired links
+	c, cleanup, err := newCollector(ctx, engine, true)
+	defer cleanup()
+	if err != nil {
+		return movie, NewEngineError(engine.GetName(), ErrEngineUnreachable, err)
+	}
+	movie.Index = 0
+	movie.DownloadLink = copyURL(movie.DetailLink)
+	movie.SDownloadLink = nil
+	movie.Seasons = nil
+	movie.Variants = nil
+	movies := []Movie{movie}
+	downloadLinkCollector := newDownloadLinkCollector(ctx, engine, c, &movies)
+
+	var resolveErr error
+	downloadLinkCollector.OnError(func(r *colly.Response, err error) {
+		// Only the detail page itself is required, other pages are best effort
+		if r.Request.URL.String() == movie.DetailLink.String() {
+			resolveErr = classifyRequestError(engine.GetName(), r, err)
+		}
 	})
-	c.Visit(engine.getParseURL().String())
-	return movies, nil
+	if err = downloadLinkCollector.Visit(movie.DetailLink.String()); err != nil && resolveErr == nil {
+		resolveErr = classifyRequestError(engine.GetName(), nil, err)
+	}
+	if err = ctx.Err(); err != nil {
+		return movie, NewEngineError(engine.GetName(), ErrEngineUnreachable, err)
+	}
+	if resolveErr != nil {
+		return movie, resolveErr
+	}
+	return movies[0], nil
 }
 
 // Movie : the structure of all downloadable movies
@@ -175,9 +327,12 @@ type Movie struct {
 	Description    string
 	Size           string
 	DownloadLink   *url.URL
+	DetailLink     *url.URL // The page of the movie on the engine the download link is resolved from
 	Year           int
 	IsSeries       bool
 	SDownloadLink  map[string]*url.URL // Other links for downloads if movies is series
+	Seasons        []Season            // Episodes of the series by season, in order
+	Variants       []Variant           // Files the movie is available as e.g in several qualities
 	Quality        string
 	Category       string // csv of categories
 	Cast           string // csv of actors in movie
@@ -193,7 +348,11 @@ type Movie struct {
 type MovieJSON struct {
 	Movie
 	DownloadLink  string
+	DetailLink    string `json:",omitempty"`
 	SDownloadLink map[string]string
+	Seasons       []Season
+	Variants      []Variant
+	SubtitleLink  string
 	SubtitleLinks map[string]string
 }
 
@@ -216,8 +375,16 @@ func (m *Movie) MarshalJSON() ([]byte, error) {
 		Movie:         *m,
 		DownloadLink:  m.DownloadLink.String(),
 		SDownloadLink: sDownloadLink,
+		Seasons:       append([]Season{}, m.Seasons...),
+		Variants:      append([]Variant{}, m.Variants...),
 		SubtitleLinks: subtitleLinks,
 	}
+	if m.DetailLink != nil {
+		movie.DetailLink = m.DetailLink.String()
+	}
+	if m.SubtitleLink != nil {
+		movie.SubtitleLink = m.SubtitleLink.String()
+	}
 
 	return json.Marshal(movie)
 
@@ -258,42 +425,15 @@ func (s *SearchResult) GetIndexFromTitle(title string) (int, error) {
 	return 0, errors.New("Movie not Found")
 }
 
-// GetEngines : Returns all the usable engines in the application
-func GetEngines() map[string]Engine {
-	engines := make(map[string]Engine)
-	engines["netnaija"] = NewNetNaijaEngine()
-	engines["fzmovies"] = NewFzEngine()
-	engines["besthdmovies"] = NewBestHDEngine()
-	engines["tvseries"] = NewTvSeriesEngine()
-	engines["mycoolmoviez"] = NewMyCoolMoviezEngine()
-	engines["coolmoviez"] = NewCoolMoviezEngine()
-	engines["animeout"] = NewAnimeOutEngine()
-	engines["takanimelist"] = NewTakanimeListEngine()
-	engines["kdramahood"] = NewKDramaHoodEngine()
-	engines["nkiri"] = NewNkiriEngine()
-	return engines
-}
-
-// GetEngine : Return an engine
-func GetEngine(engine string) (Engine, error) {
-	e := GetEngines()[strings.ToLower(engine)]
-	if e == nil {
-		return nil, fmt.Errorf("Engine %s Does not exist", engine)
-	}
-	return e, nil
-}
-
-// Get the movie index context stored in Request
-func getMovieIndexFromCtx(r *colly.Request) int {
-	movieIndex, err := strconv.Atoi(r.Ctx.Get("movieIndex"))
-	if err != nil {
-		log.Fatal(err)
-	}
+// GetMovieIndexFromCtx : Get the movie index context stored in Request
+// Scrape aborts every download request without a movie index so it is always present
+func GetMovieIndexFromCtx(r *colly.Request) int {
+	movieIndex, _ := strconv.Atoi(r.Ctx.Get("movieIndex"))
 	return movieIndex
 }
 
-// Get all form details into a neat map
-func getFormDetails(element *colly.HTMLElement) map[string]string {
+// GetFormDetails : Get all form details into a neat map
+func GetFormDetails(element *colly.HTMLElement) map[string]string {
 	submission := make(map[string]string)
 	inputNames := element.ChildAttrs("input", "name")
 	inputValues := element.ChildAttrs("input", "value")
@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Episode : an episode of a series and its download link
+type Episode struct {
+	Season   int
+	Number   int
+	Title    string // Title of the episode on the engine
+	Link     *url.URL
+	Size     string
+	Subtitle *url.URL  // Subtitle of the episode if available
+	Variants []Variant `json:",omitempty"` // Files the episode is available as if there are several
+}
+
+// EpisodeJSON : JSON structure of an episode
+type EpisodeJSON struct {
+	Episode
+	Link     string
+	Subtitle string `json:",omitempty"`
+}
+
+// MarshalJSON Json structure to return from api
+func (e *Episode) MarshalJSON() ([]byte, error) {
+	episode := EpisodeJSON{Episode: *e}
+	if e.Link != nil {
+		episode.Link = e.Link.String()
+	}
+	if e.Subtitle != nil {
+		episode.Subtitle = e.Subtitle.String()
+	}
+	return json.Marshal(episode)
+}
+
+// Code : the season and episode of the episode, e.g S01E03
+func (e Episode) Code() string {
+	return fmt.Sprintf("S%02dE%02d", e.Season, e.Number)
+}
+
+// Season : the episodes of a season of a series in order
+type Season struct {
+	Number   int
+	Episodes []Episode
+}
+
+// Patterns of the season and episode numbers in the titles of episodes
+var (
+	seasonEpisodeRe = regexp.MustCompile(`(?i)\bS(\d{1,2})\s*E(\d{1,3})\b`)
+	crossEpisodeRe  = regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{1,3})\b`)
+	namedEpisodeRe  = regexp.MustCompile(`(?i)\b(?:episode|ep)\.?\s*(\d{1,3})\b`)
+	dashEpisodeRe   = regexp.MustCompile(`\s-\s(\d{1,3})\b`)
+	onlyNumberRe    = regexp.MustCompile(`^\s*(\d{1,3})\s*$`)
+	seasonRe        = regexp.MustCompile(`(?i)\b(?:season\s*|S)(\d{1,2})\b`)
+)
+
+// ParseEpisode : the season and episode numbers in the title of an episode, zero when not found
+func ParseEpisode(title string) (season, number int) {
+	season, number, _ = parseEpisode(title)
+	return season, number
+}
+
+// parseEpisode : the season and episode numbers in title, and whether an episode number was found
+func parseEpisode(title string) (season, number int, found bool) {
+	title = strings.NewReplacer(".", " ", "_", " ").Replace(title)
+	if match := seasonEpisodeRe.FindStringSubmatch(title); match != nil {
+		season, _ = strconv.Atoi(match[1])
+		number, _ = strconv.Atoi(match[2])
+		return season, number, true
+	}
+	if match := crossEpisodeRe.FindStringSubmatch(title); match != nil {
+		season, _ = strconv.Atoi(match[1])
+		number, _ = strconv.Atoi(match[2])
+		return season, number, true
+	}
+	season = parseSeason(title)
+	for _, re := range []*regexp.Regexp{namedEpisodeRe, dashEpisodeRe, onlyNumberRe} {
+		if match := re.FindStringSubmatch(title); match != nil {
+			number, _ = strconv.Atoi(match[1])
+			return season, number, true
+		}
+	}
+	return season, 0, false
+}
+
+// parseSeason : the season number in title e.g Season 2 or S2, zero when not found
+func parseSeason(title string) int {
+	match := seasonRe.FindStringSubmatch(strings.NewReplacer(".", " ", "_", " ").Replace(title))
+	if match == nil {
+		return 0
+	}
+	season, _ := strconv.Atoi(match[1])
+	return season
+}
+
+// seriesSeason : the season in the title of a series, the first if it is not found
+func seriesSeason(series string) int {
+	if season := parseSeason(series); season > 0 {
+		return season
+	}
+	return 1
+}
+
+// EpisodesFromLinks : the episodes of the series titled series from its links and subtitles by
+// the titles of its episodes. Seasons missing from the titles of episodes are taken from the title
+// of the series. Episodes without a number follow the numbered episodes of their season, in order
+// of their titles
+func EpisodesFromLinks(series string, links, subtitles map[string]*url.URL) []Episode {
+	var episodes []Episode
+	for title, link := range links {
+		season, number, found := parseEpisode(title)
+		if season == 0 {
+			season = seriesSeason(series)
+		}
+		if !found {
+			// Numbered once the numbers of the other episodes are known
+			number = -1
+		}
+		episodes = append(episodes, Episode{
+			Season:   season,
+			Number:   number,
+			Title:    title,
+			Link:     link,
+			Subtitle: subtitles[title],
+		})
+	}
+	sortEpisodes(episodes)
+	// Seasons with an episode zero are links listed by their position on the page from zero
+	fromZero := map[int]bool{}
+	for _, episode := range episodes {
+		fromZero[episode.Season] = fromZero[episode.Season] || episode.Number == 0
+	}
+	highest := map[int]int{}
+	for i := range episodes {
+		episode := &episodes[i]
+		if fromZero[episode.Season] && episode.Number >= 0 {
+			episode.Number++
+		}
+		if episode.Number > highest[episode.Season] {
+			highest[episode.Season] = episode.Number
+		}
+	}
+	for i := range episodes {
+		if episode := &episodes[i]; episode.Number < 0 {
+			highest[episode.Season]++
+			episode.Number = highest[episode.Season]
+		}
+	}
+	sortEpisodes(episodes)
+	return episodes
+}
+
+// sortEpisodes : order episodes by season and number
+func sortEpisodes(episodes []Episode) {
+	sort.SliceStable(episodes, func(i, j int) bool {
+		a, b := episodes[i], episodes[j]
+		if a.Season != b.Season {
+			return a.Season < b.Season
+		}
+		if a.Number != b.Number {
+			return a.Number < b.Number
+		}
+		return a.Title < b.Title
+	})
+}
+
+// GroupSeasons : episodes grouped by season, with seasons and episodes in order
+func GroupSeasons(episodes []Episode) []Season {
+	episodes = append([]Episode(nil), episodes...)
+	sortEpisodes(episodes)
+	var seasons []Season
+	for _, episode := range episodes {
+		if len(seasons) == 0 || seasons[len(seasons)-1].Number != episode.Season {
+			seasons = append(seasons, Season{Number: episode.Season})
+		}
+		last := &seasons[len(seasons)-1]
+		last.Episodes = append(last.Episodes, episode)
+	}
+	return seasons
+}
+
+// Episodes : the episodes of a series ordered by season and number
+// The episodes of engines that do not fill the seasons of series are found from SDownloadLink
+func (m *Movie) Episodes() []Episode {
+	if len(m.Seasons) == 0 {
+		return EpisodesFromLinks(m.Title, m.SDownloadLink, m.SubtitleLinks)
+	}
+	var episodes []Episode
+	for _, season := range m.Seasons {
+		episodes = append(episodes, season.Episodes...)
+	}
+	return episodes
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Kinds of errors returned by engines. Use errors.Is to check for them
+var (
+	// ErrEngineUnreachable : the site of the engine could not be reached
+	ErrEngineUnreachable = errors.New("engine unreachable")
+	// ErrLayoutChanged : the page was retrieved but could not be parsed
+	ErrLayoutChanged = errors.New("engine layout changed")
+	// ErrNoResults : the page was parsed but contained no movies
+	ErrNoResults = errors.New("no results found")
+	// ErrBlockedByCloudflare : the request was blocked by a cloudflare challenge
+	ErrBlockedByCloudflare = errors.New("blocked by cloudflare")
+)
+
+// EngineError : an error that occurred while scraping an engine
+type EngineError struct {
+	Engine string // Name of the engine
+	Kind   error  // One of the Err* kinds above
+	Err    error  // The underlying error if any
+}
+
+func (e *EngineError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v: %v", e.Engine, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Engine, e.Kind)
+}
+
+// Is : reports whether target is the kind of this error
+func (e *EngineError) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Unwrap : returns the underlying error
+func (e *EngineError) Unwrap() error {
+	return e.Err
+}
+
+// NewEngineError : create an error of the given kind for an engine
+// Engines implemented outside this package use it so that their errors can be checked with errors.Is
+func NewEngineError(engine string, kind, err error) *EngineError {
+	return &EngineError{
+		Engine: engine,
+		Kind:   kind,
+		Err:    err,
+	}
+}
+
+// classifyRequestError : determine the kind of error from a failed colly request
+func classifyRequestError(engine string, r *colly.Response, err error) error {
+	if r != nil && isCloudflareBlock(r) {
+		return NewEngineError(engine, ErrBlockedByCloudflare, err)
+	}
+	return NewEngineError(engine, ErrEngineUnreachable, err)
+}
+
+// Cloudflare challenges are served with a 403 or 503 from a cloudflare server
+func isCloudflareBlock(r *colly.Response) bool {
+	if r.Headers == nil {
+		return false
+	}
+	if r.StatusCode != http.StatusForbidden && r.StatusCode != http.StatusServiceUnavailable {
+		return false
+	}
+	return strings.Contains(strings.ToLower(r.Headers.Get("Server")), "cloudflare") ||
+		r.Headers.Get("Cf-Ray") != ""
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FederatedResult : the merged results of searching several engines at once
+type FederatedResult struct {
+	SearchResult
+	Groups []MovieGroup     // Equivalent movies grouped and ranked by relevance to the query
+	Failed map[string]error // Engines that failed to return results and why
+}
+
+// FederatedResultJSON : JSON structure of the merged results
+type FederatedResultJSON struct {
+	SearchResult
+	Groups []MovieGroup
+	Failed map[string]string
+}
+
+// MarshalJSON Json structure to return from api
+func (r *FederatedResult) MarshalJSON() ([]byte, error) {
+	failed := make(map[string]string)
+	for name, err := range r.Failed {
+		failed[name] = err.Error()
+	}
+	return json.Marshal(FederatedResultJSON{
+		SearchResult: r.SearchResult,
+		Groups:       r.Groups,
+		Failed:       failed,
+	})
+}
+
+// SearchAll : Searches all engines concurrently and merges their movies into a single result
+// Each engine is given at most timeout to respond (0 for no limit). The movies keep the
+// Source of the engine they came from and are ordered by engine name, while Groups holds
+// them de-duplicated and ranked by relevance. Engines without results are not failures.
+// An error is only returned when no engine returned any movie, or no query is given
+func SearchAll(ctx context.Context, engines map[string]Engine, timeout time.Duration, param ...string) (FederatedResult, error) {
+	if len(param) == 0 {
+		return FederatedResult{Failed: map[string]error{}}, errors.New("no query to search for")
+	}
+	type engineResult struct {
+		name   string
+		result SearchResult
+		err    error
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan engineResult, len(engines))
+	for name, e := range engines {
+		wg.Add(1)
+		go func(name string, e Engine) {
+			defer wg.Done()
+			engineCtx := ctx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				engineCtx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			result, err := e.Search(engineCtx, param...)
+			results <- engineResult{name: name, result: result, err: err}
+		}(name, e)
+	}
+	wg.Wait()
+	close(results)
+
+	var collected []engineResult
+	for r := range results {
+		collected = append(collected, r)
+	}
+	sort.Slice(collected, func(i, j int) bool { return collected[i].name < collected[j].name })
+
+	federated := FederatedResult{
+		SearchResult: SearchResult{Query: param[0]},
+		Failed:       map[string]error{},
+	}
+	var firstFailure error
+	for _, r := range collected {
+		if errors.Is(r.err, ErrNoResults) {
+			continue
+		}
+		if r.err != nil {
+			federated.Failed[r.name] = r.err
+			if firstFailure == nil {
+				firstFailure = r.err
+			}
+			continue
+		}
+		for _, movie := range r.result.Movies {
+			movie.Index = len(federated.Movies)
+			federated.Movies = append(federated.Movies, movie)
+		}
+	}
+
+	federated.Groups = federated.Group()
+
+	if len(federated.Movies) > 0 {
+		return federated, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return federated, NewEngineError("all", ErrEngineUnreachable, err)
+	}
+	if len(federated.Failed) > 0 && len(federated.Failed) == len(engines) {
+		// Every engine failed, the first by name tells why
+		return federated, firstFailure
+	}
+	return federated, NewEngineError("all", ErrNoResults, nil)
+}
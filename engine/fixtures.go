@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-phie/gophie/transport"
+)
+
+// RecordFixtures : Search and List an engine while recording every page retrieved to dir/pages
+// The results are saved to dir/search.json and dir/list.json so the engine can be tested
+// offline by replaying the pages and comparing its results. Existing fixtures are only
+// replaced once both succeed
+func RecordFixtures(ctx context.Context, e Engine, dir, query string) error {
+	pages := filepath.Join(dir, "pages")
+	recording := pages + ".recording"
+	if err := os.RemoveAll(recording); err != nil {
+		return err
+	}
+	defer os.RemoveAll(recording)
+	recorder, err := transport.NewRecordTransport(recording, Transport)
+	if err != nil {
+		return err
+	}
+	previous := Transport
+	Transport = recorder
+	defer func() { Transport = previous }()
+
+	search, err := e.Search(ctx, query)
+	if err != nil {
+		return err
+	}
+	list, err := e.List(ctx, 1)
+	if err != nil {
+		return err
+	}
+
+	// Pages that are no longer retrieved are not kept around
+	if err = os.RemoveAll(pages); err != nil {
+		return err
+	}
+	if err = os.Rename(recording, pages); err != nil {
+		return err
+	}
+	if err = writeFixtureResult(filepath.Join(dir, "search.json"), search); err != nil {
+		return err
+	}
+	return writeFixtureResult(filepath.Join(dir, "list.json"), list)
+}
+
+// FixtureQuery : the query that was searched for when the fixtures in dir were recorded
+func FixtureQuery(dir string) (string, error) {
+	var recorded struct{ Query string }
+	b, err := ioutil.ReadFile(filepath.Join(dir, "search.json"))
+	if err != nil {
+		return "", err
+	}
+	if err = json.Unmarshal(b, &recorded); err != nil {
+		return "", err
+	}
+	return recorded.Query, nil
+}
+
+// writeFixtureResult : save result as indented JSON to file
+func writeFixtureResult(file string, result SearchResult) error {
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(b, '\n'), 0644)
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"path"
@@ -54,11 +55,11 @@ func (engine *FzEngine) String() string {
 	return st
 }
 
-func (engine *FzEngine) getParseAttrs() (string, string, error) {
+func (engine *FzEngine) GetParseAttrs(req *Request) (string, string, error) {
 	return "body", "div.mainbox", nil
 }
 
-func (engine *FzEngine) parseSingleMovie(el *colly.HTMLElement, index int) (Movie, error) {
+func (engine *FzEngine) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
 	movie := Movie{
 		Index:    index,
 		IsSeries: false,
@@ -66,7 +67,7 @@ func (engine *FzEngine) parseSingleMovie(el *colly.HTMLElement, index int) (Movi
 	}
 	cover, err := url.Parse(el.Request.AbsoluteURL(el.ChildAttr("img", "src")))
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 	movie.CoverPhotoLink = cover.String()
 	// Remove all Video: or Movie: Prefixes
@@ -94,7 +95,7 @@ func (engine *FzEngine) parseSingleMovie(el *colly.HTMLElement, index int) (Movi
 	downloadLink, err := url.Parse(el.Request.AbsoluteURL(el.ChildAttr("a", "href")))
 
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 	downloadLink.Path = path.Join(engine.BaseURL.Path, downloadLink.Path)
 
@@ -102,44 +103,47 @@ func (engine *FzEngine) parseSingleMovie(el *colly.HTMLElement, index int) (Movi
 	return movie, nil
 }
 
-func (engine *FzEngine) updateDownloadProps(downloadCollector *colly.Collector, movies *[]Movie) {
-	// Update movie download link if ul.downloadlinks on page
+func (engine *FzEngine) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
+	// Every file the movie is available as e.g High MP4 and MP4 leads to its own download page
 	downloadCollector.OnHTML("ul.ptype", func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
-		link := strings.Replace(e.ChildAttr("a", "href"), "download1.php", "download.php", 1)
-		downloadLink, err := url.Parse(e.Request.AbsoluteURL(link + "&pt=jRGarGzOo2"))
-		if err != nil {
-			log.Fatal(err)
-		}
-		movie.DownloadLink = downloadLink
-		re := regexp.MustCompile(`(.* MB)`)
-		stringsub := re.FindStringSubmatch(e.ChildText("dcounter"))
-		if len(stringsub) > 0 {
-			dl := strings.TrimPrefix(stringsub[0], "(")
-			dlRe := regexp.MustCompile(`(\d+ MB)`)
-			dlSize := dlRe.FindStringSubmatch(dl)
-			if len(dlSize) > 1 {
-				movie.Size = dlSize[1]
-			} else {
-				movie.Size = dl
-			}
-		}
+		movieIndex := GetMovieIndexFromCtx(e.Request)
+		movie := &(*movies)[movieIndex]
 		if strings.HasSuffix(movie.Title, "Tags") {
 			movie.Title = strings.TrimSuffix(movie.Title, "Tags")
 		}
-		downloadCollector.Visit(downloadLink.String())
+		e.ForEach("li", func(_ int, option *colly.HTMLElement) {
+			link := strings.Replace(option.ChildAttr("a", "href"), "download1.php", "download.php", 1)
+			if link == "" {
+				return
+			}
+			downloadLink, err := url.Parse(e.Request.AbsoluteURL(link + "&pt=jRGarGzOo2"))
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			quality := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(option.ChildText("a")), "Download "), " File")
+			variant := movie.AddVariant(NewVariant(quality, fzSize(option.ChildText("dcounter")), downloadLink))
+			if variant == 0 {
+				movie.DownloadLink = downloadLink
+				movie.Size = movie.Variants[0].Size
+			}
+			if err = variantRequest(downloadCollector, movieIndex, variant, downloadLink.String()); err != nil {
+				log.Debug(err)
+			}
+		})
 	})
 
 	downloadCollector.OnHTML("ul.downloadlinks", func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		links := e.ChildAttrs("a", "href")
 		if len(links) > 1 {
 			downloadLink, err := url.Parse(e.Request.AbsoluteURL(links[len(links)-1]))
 			if err != nil {
-				log.Fatal(err)
+				log.Error(err)
+				return
 			}
-			movie.DownloadLink = downloadLink
-			downloadCollector.Visit(downloadLink.String())
+			updateVariant(movie, e.Request, func(variant *Variant) { variant.Link = downloadLink })
+			e.Request.Visit(downloadLink.String())
 		}
 	})
 
@@ -149,46 +153,63 @@ func (engine *FzEngine) updateDownloadProps(downloadCollector *colly.Collector,
 		if strings.HasSuffix(trimmedValue, "mp4") || strings.HasSuffix(trimmedValue, "mp4?fromwebsite") {
 			downloadLink, err := url.Parse(e.Request.AbsoluteURL(e.Attr("value")))
 			if err != nil {
-				log.Fatal(err)
+				log.Error(err)
+				return
 			}
-			(*movies)[getMovieIndexFromCtx(e.Request)].DownloadLink = downloadLink
+			movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
+			updateVariant(movie, e.Request, func(variant *Variant) { variant.Link = downloadLink })
 		}
 	})
 }
 
+// fzSize : the size of a file from its download counter e.g (812 MB) | Downloaded 10254 times
+func fzSize(counter string) string {
+	re := regexp.MustCompile(`(.* MB)`)
+	stringsub := re.FindStringSubmatch(counter)
+	if len(stringsub) == 0 {
+		return ""
+	}
+	dl := strings.TrimPrefix(stringsub[0], "(")
+	dlRe := regexp.MustCompile(`(\d+ MB)`)
+	if dlSize := dlRe.FindStringSubmatch(dl); len(dlSize) > 1 {
+		return dlSize[1]
+	}
+	return dl
+}
+
 // List : list all the movies on a page
-func (engine *FzEngine) List(page int) SearchResult {
-	engine.mode = ListMode
+func (engine *FzEngine) List(ctx context.Context, page int) (SearchResult, error) {
+	req := engine.NewListRequest(page)
 	result := SearchResult{
 		Query: "List of Recent Uploads - Page " + strconv.Itoa(page),
 	}
-	q := engine.ListURL.Query()
+	q := req.URL.Query()
 	q.Set("catID", "2")
 	q.Set("by", "date")
 	q.Set("pg", strconv.Itoa(page))
-	engine.ListURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
 
 // Search : Searches fzmovies for a particular query and return an array of movies
-func (engine *FzEngine) Search(param ...string) SearchResult {
+func (engine *FzEngine) Search(ctx context.Context, param ...string) (SearchResult, error) {
 	query := param[0]
-	engine.mode = SearchMode
+	req := engine.NewSearchRequest(query)
 	result := SearchResult{
 		Query: query,
 	}
-	q := engine.SearchURL.Query()
+	q := req.URL.Query()
 	q.Set("searchname", query)
-	engine.SearchURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
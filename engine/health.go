@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Health statuses of an engine, from best to worst
+const (
+	// HealthOK : movies were returned and their download links resolved to media files
+	HealthOK = "ok"
+	// HealthDegraded : movies were returned but none of their download links resolved to a media file
+	HealthDegraded = "degraded"
+	// HealthDown : the engine failed or returned no movies
+	HealthDown = "down"
+)
+
+// Number of movies whose download links are checked by a canary
+const canaryMovies = 5
+
+var (
+	// Queries known to return results on engines that do not have movies for the default query
+	canaryQueries = map[string]string{
+		"tvseries":     "devs",
+		"animeout":     "attack on titans",
+		"takanimelist": "attack on titans",
+		"kdramahood":   "flower of evil",
+	}
+	mediaExtensions = []string{".mp4", ".mkv", ".avi", ".webm", ".m4v", ".mov", ".3gp"}
+)
+
+// CanaryQuery : the query searched for when checking the health of an engine
+func CanaryQuery(name string) string {
+	if query, ok := canaryQueries[strings.ToLower(name)]; ok {
+		return query
+	}
+	return "jumanji"
+}
+
+// CanaryResult : the outcome of a canary search or list on an engine
+type CanaryResult struct {
+	Status   string
+	Latency  time.Duration
+	Movies   int   // Number of movies returned
+	Checked  int   // Number of movies whose download links were checked
+	Resolved int   // Number of checked movies whose download links resolved to a media file
+	Err      error // Why the engine is down if it is
+}
+
+// CanaryResultJSON : JSON structure of a canary result
+type CanaryResultJSON struct {
+	CanaryResult
+	Latency   string
+	LatencyMs int64
+	Err       string `json:"Error,omitempty"`
+}
+
+// MarshalJSON Json structure to return from api
+func (c *CanaryResult) MarshalJSON() ([]byte, error) {
+	result := CanaryResultJSON{
+		CanaryResult: *c,
+		Latency:      c.Latency.Round(time.Millisecond).String(),
+		LatencyMs:    c.Latency.Milliseconds(),
+	}
+	if c.Err != nil {
+		result.Err = c.Err.Error()
+	}
+	return json.Marshal(result)
+}
+
+// Health : the health of an engine
+type Health struct {
+	Engine string
+	Status string // The worst status of Search and List
+	Query  string // The query searched for
+	Search CanaryResult
+	List   CanaryResult
+}
+
+// CheckEngine : run a canary search for query and list of the first page on an engine
+func CheckEngine(ctx context.Context, name string, e Engine, query string) Health {
+	health := Health{
+		Engine: name,
+		Query:  query,
+	}
+	health.Search = runCanary(ctx, func(ctx context.Context) (SearchResult, error) { return e.Search(ctx, query) })
+	health.List = runCanary(ctx, func(ctx context.Context) (SearchResult, error) { return e.List(ctx, 1) })
+	health.Status = worstStatus(health.Search.Status, health.List.Status)
+	return health
+}
+
+// CheckEngines : check the health of engines concurrently, ordered by name
+// Each engine is given at most timeout to respond to each canary (0 for no limit)
+func CheckEngines(ctx context.Context, engines map[string]Engine, timeout time.Duration) []Health {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		health []Health
+	)
+	for name, e := range engines {
+		wg.Add(1)
+		go func(name string, e Engine) {
+			defer wg.Done()
+			engineCtx := ctx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				engineCtx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			h := CheckEngine(engineCtx, name, e, CanaryQuery(name))
+			mu.Lock()
+			health = append(health, h)
+			mu.Unlock()
+		}(name, e)
+	}
+	wg.Wait()
+	sort.Slice(health, func(i, j int) bool { return health[i].Engine < health[j].Engine })
+	return health
+}
+
+func runCanary(ctx context.Context, fetch func(context.Context) (SearchResult, error)) CanaryResult {
+	start := time.Now()
+	result, err := fetch(ctx)
+	canary := CanaryResult{
+		Latency: time.Since(start),
+		Movies:  len(result.Movies),
+		Err:     err,
+	}
+	if err != nil || canary.Movies == 0 {
+		if err == nil {
+			canary.Err = ErrNoResults
+		}
+		canary.Status = HealthDown
+		return canary
+	}
+	for _, movie := range result.Movies {
+		if canary.Checked == canaryMovies {
+			break
+		}
+		canary.Checked++
+		if resolvesToMedia(ctx, movie) {
+			canary.Resolved++
+		}
+	}
+	canary.Status = HealthOK
+	if canary.Resolved == 0 {
+		canary.Status = HealthDegraded
+	}
+	return canary
+}
+
+// resolvesToMedia : reports whether the download link of a movie, or of any of its episodes, is a media file
+func resolvesToMedia(ctx context.Context, movie Movie) bool {
+	if movie.DownloadLink != nil && isMediaLink(ctx, movie.DownloadLink.String()) {
+		return true
+	}
+	for _, episode := range movie.Episodes() {
+		if episode.Link != nil && isMediaLink(ctx, episode.Link.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMediaLink : reports whether link has the extension of a media file or is served as one
+func isMediaLink(ctx context.Context, link string) bool {
+	if link == "" {
+		return false
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err != nil {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(req.URL.Path))
+	for _, mediaExt := range mediaExtensions {
+		if ext == mediaExt {
+			return true
+		}
+	}
+	resp, err := (&http.Client{Transport: Transport}).Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	contentType := resp.Header.Get("Content-Type")
+	return resp.StatusCode == http.StatusOK &&
+		(strings.HasPrefix(contentType, "video/") || strings.HasPrefix(contentType, "application/octet-stream"))
+}
+
+// worstStatus : the worst of the given health statuses
+func worstStatus(statuses ...string) string {
+	worst := HealthOK
+	for _, status := range statuses {
+		switch {
+		case status == HealthDown:
+			return HealthDown
+		case status == HealthDegraded:
+			worst = HealthDegraded
+		}
+	}
+	return worst
+}
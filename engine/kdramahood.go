@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"path"
@@ -53,18 +54,18 @@ func (engine *KDramaHood) String() string {
 	return st
 }
 
-func (engine *KDramaHood) getParseAttrs() (string, string, error) {
+func (engine *KDramaHood) GetParseAttrs(req *Request) (string, string, error) {
 	return "div.items", "div.item", nil
 }
 
-func (engine *KDramaHood) parseSingleMovie(el *colly.HTMLElement, index int) (Movie, error) {
+func (engine *KDramaHood) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
 	movie := Movie{
 		Index:    index,
 		IsSeries: true,
 		Source:   engine.Name,
 		Size:     "---MB",
 	}
-	switch engine.mode {
+	switch req.Mode {
 	case SearchMode:
 		movie.Title = strings.TrimSpace(el.ChildText("span.tt"))
 		movie.Description = strings.TrimSpace(el.ChildText("span.ttx"))
@@ -77,14 +78,14 @@ func (engine *KDramaHood) parseSingleMovie(el *colly.HTMLElement, index int) (Mo
 	downloadLink, err := url.Parse(link)
 
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 	movie.DownloadLink = downloadLink
 	movie.Category = "kdrama"
 	return movie, nil
 }
 
-func (engine *KDramaHood) updateDownloadProps(downloadCollector *colly.Collector, movies *[]Movie) {
+func (engine *KDramaHood) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
 	innerCollector := downloadCollector.Clone()
 	episodeMap := map[string]*url.URL{}
 	subtitleMap := map[string]*url.URL{}
@@ -99,7 +100,7 @@ func (engine *KDramaHood) updateDownloadProps(downloadCollector *colly.Collector
 		// create local targets
 		targetepisode := make(map[string]*url.URL)
 		targetsub := make(map[string]*url.URL)
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		e.ForEach("li", func(_ int, inn *colly.HTMLElement) {
 			innerCollector.Visit(inn.ChildAttr("a", "href"))
 		})
@@ -114,6 +115,7 @@ func (engine *KDramaHood) updateDownloadProps(downloadCollector *colly.Collector
 
 		movie.SDownloadLink = targetepisode
 		movie.SubtitleLinks = targetsub
+		movie.Seasons = GroupSeasons(EpisodesFromLinks(movie.Title, targetepisode, targetsub))
 	})
 
 	innerCollector.OnHTML("div.linkstv", func(e *colly.HTMLElement) {
@@ -131,35 +133,35 @@ func (engine *KDramaHood) updateDownloadProps(downloadCollector *colly.Collector
 }
 
 // List : list all the movies on a page
-func (engine *KDramaHood) List(page int) SearchResult {
-	engine.mode = ListMode
+func (engine *KDramaHood) List(ctx context.Context, page int) (SearchResult, error) {
+	req := engine.NewListRequest(page)
 	result := SearchResult{
 		Query: "List of Recent Uploads - Page " + strconv.Itoa(page),
 	}
 	pageParam := fmt.Sprintf("page/%v", strconv.Itoa(page))
-	engine.ListURL.Path = path.Join(engine.ListURL.Path, pageParam)
-	movies, err := Scrape(engine)
+	req.URL.Path = path.Join(req.URL.Path, pageParam)
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
 
 // Search : Searches fzmovies for a particular query and return an array of movies
-func (engine *KDramaHood) Search(param ...string) SearchResult {
+func (engine *KDramaHood) Search(ctx context.Context, param ...string) (SearchResult, error) {
 	query := param[0]
-	engine.mode = SearchMode
+	req := engine.NewSearchRequest(query)
 	result := SearchResult{
 		Query: query,
 	}
-	q := engine.SearchURL.Query()
+	q := req.URL.Query()
 	q.Set("s", query)
-	engine.SearchURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
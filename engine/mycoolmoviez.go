@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"path"
@@ -54,11 +55,11 @@ func (engine *MyCoolMoviez) String() string {
 	return st
 }
 
-func (engine *MyCoolMoviez) getParseAttrs() (string, string, error) {
+func (engine *MyCoolMoviez) GetParseAttrs(req *Request) (string, string, error) {
 	return "ul.cat_ul", "li", nil
 }
 
-func (engine *MyCoolMoviez) parseSingleMovie(el *colly.HTMLElement, index int) (Movie, error) {
+func (engine *MyCoolMoviez) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
 	movie := Movie{
 		Index:    index,
 		IsSeries: false,
@@ -74,26 +75,27 @@ func (engine *MyCoolMoviez) parseSingleMovie(el *colly.HTMLElement, index int) (
 	downloadLink, err := url.Parse(el.Request.AbsoluteURL(el.ChildAttr("a", "href")))
 
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 
 	movie.DownloadLink = downloadLink
 	return movie, nil
 }
 
-func (engine *MyCoolMoviez) updateDownloadProps(downloadCollector *colly.Collector, movies *[]Movie) {
+func (engine *MyCoolMoviez) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
 	downloadCollector.OnHTML("img.movie-poster", func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		coverphotolink, err := url.Parse(e.Attr("src"))
 		if err != nil {
-			log.Fatal(err)
+			log.Error(err)
+			return
 		}
 		movie.CoverPhotoLink = coverphotolink.String()
 	})
 
 	downloadCollector.OnHTML("div.panel-body", func(e *colly.HTMLElement) {
 		var genre string
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		listTexts := e.ChildTexts("li")
 		for _, text := range listTexts {
 			if strings.HasPrefix(text, "Description :") {
@@ -107,7 +109,7 @@ func (engine *MyCoolMoviez) updateDownloadProps(downloadCollector *colly.Collect
 	})
 
 	downloadCollector.OnHTML("div.download", func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		listHrefs := e.ChildAttrs("a", "href")
 		for _, link := range listHrefs {
 			if strings.HasPrefix(link, "https://") {
@@ -124,7 +126,7 @@ func (engine *MyCoolMoviez) updateDownloadProps(downloadCollector *colly.Collect
 	})
 
 	downloadCollector.OnHTML(`a[rel="nofollow"]`, func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		if strings.HasPrefix(e.Attr("title"), "Download from") {
 			downloadLink, _ := url.Parse(e.Attr("href"))
 			movie.DownloadLink = downloadLink
@@ -133,35 +135,35 @@ func (engine *MyCoolMoviez) updateDownloadProps(downloadCollector *colly.Collect
 }
 
 // List : list all the movies on a page
-func (engine *MyCoolMoviez) List(page int) SearchResult {
-	engine.mode = ListMode
+func (engine *MyCoolMoviez) List(ctx context.Context, page int) (SearchResult, error) {
+	req := engine.NewListRequest(page)
 	result := SearchResult{
 		Query: "List of Recent Uploads - Page " + strconv.Itoa(page),
 	}
 	pageParam := fmt.Sprintf("%v/", strconv.Itoa(page-1))
-	engine.ListURL.Path = path.Join(engine.ListURL.Path, pageParam) + "/"
-	movies, err := Scrape(engine)
+	req.URL.Path = path.Join(req.URL.Path, pageParam) + "/"
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
 
 // Search : Searches fzmovies for a particular query and return an array of movies
-func (engine *MyCoolMoviez) Search(param ...string) SearchResult {
+func (engine *MyCoolMoviez) Search(ctx context.Context, param ...string) (SearchResult, error) {
 	query := param[0]
-	engine.mode = SearchMode
+	req := engine.NewSearchRequest(query)
 	result := SearchResult{
 		Query: query,
 	}
-	q := engine.SearchURL.Query()
+	q := req.URL.Query()
 	q.Set("movie", query)
-	engine.SearchURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -58,13 +59,13 @@ func (engine *NetNaijaEngine) String() string {
 	return fmt.Sprintf("%s (%s)", engine.Name, engine.BaseURL)
 }
 
-func (engine *NetNaijaEngine) getParseAttrs() (string, string, error) {
+func (engine *NetNaijaEngine) GetParseAttrs(req *Request) (string, string, error) {
 	var (
 		article string
 		main    string
 	)
 	// When in search mode, results are in <article class="result">
-	switch engine.mode {
+	switch req.Mode {
 	case SearchMode:
 		article = "article.sr-one"
 		main = "main"
@@ -72,15 +73,15 @@ func (engine *NetNaijaEngine) getParseAttrs() (string, string, error) {
 		main = "div.video-files"
 		article = "article.file-one"
 	default:
-		return "", "", fmt.Errorf("Invalid mode %v", engine.mode)
+		return "", "", fmt.Errorf("Invalid mode %v", req.Mode)
 	}
 	return main, article, nil
 }
 
-func (engine *NetNaijaEngine) parseSingleMovie(el *colly.HTMLElement, index int) (Movie, error) {
+func (engine *NetNaijaEngine) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
 	// movie title identifier
 	var title string
-	if title = "h2"; engine.mode == SearchMode {
+	if title = "h2"; req.Mode == SearchMode {
 		title = "h3"
 	}
 
@@ -102,7 +103,7 @@ func (engine *NetNaijaEngine) parseSingleMovie(el *colly.HTMLElement, index int)
 	downloadLink, err := url.Parse(el.ChildAttr("a", "href"))
 
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 
 	if strings.HasPrefix(downloadLink.Path, "/videos/series") {
@@ -133,7 +134,7 @@ func (engine *NetNaijaEngine) getDownloadToken(sabiShareURL string) string {
 	return partsSplitBySlash[index]
 }
 
-func (engine *NetNaijaEngine) updateDownloadProps(downloadCollector *colly.Collector, movies *[]Movie) {
+func (engine *NetNaijaEngine) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
 
 	sabiShareAPI := "https://api.sabishare.com/token/download/"
 	sabiShareURL := ""
@@ -153,14 +154,23 @@ func (engine *NetNaijaEngine) updateDownloadProps(downloadCollector *colly.Colle
 	downloadCollector.OnScraped(func(r *colly.Response) {
 		// Do this operation only when we are on the download page.
 		if strings.HasSuffix(r.Request.URL.Path, "download") {
-			movieIndex := getMovieIndexFromCtx(r.Request)
+			movieIndex := GetMovieIndexFromCtx(r.Request)
 			movie := &((*movies)[movieIndex])
 			// Start by setting the default downloadURL to the sabiShare URL
 			downloadURL, _ := url.Parse(sabiShareURL)
 			movie.DownloadLink = downloadURL
 
 			token := engine.getDownloadToken(sabiShareURL)
-			resp, tokenErr := http.Get(fmt.Sprintf("%s%s", sabiShareAPI, token))
+			req, tokenErr := http.NewRequestWithContext(ctx, http.MethodGet, sabiShareAPI+token, nil)
+			if tokenErr != nil {
+				log.Error(tokenErr)
+				return
+			}
+			resp, tokenErr := (&http.Client{Transport: Transport}).Do(req)
+			if tokenErr != nil {
+				log.Errorf("Could not retrieve SabiShare token: %v", tokenErr)
+				return
+			}
 			type DownloadResponse struct {
 				Status int `json:"status"`
 				Data   struct {
@@ -172,25 +182,39 @@ func (engine *NetNaijaEngine) updateDownloadProps(downloadCollector *colly.Colle
 
 			defer resp.Body.Close()
 			body, err := io.ReadAll(resp.Body)
-			err = json.Unmarshal(body, &downloadResp)
-			if tokenErr == nil {
-				if err == nil && downloadResp.Status == 200 {
-					downloadURL, _ := url.Parse(downloadResp.Data.URL)
-					movie.DownloadLink = downloadURL
-					sabiShareURL = ""
-				}
+			if err == nil {
+				err = json.Unmarshal(body, &downloadResp)
+			}
+			if err == nil && downloadResp.Status == 200 {
+				downloadURL, _ := url.Parse(downloadResp.Data.URL)
+				movie.DownloadLink = downloadURL
+				sabiShareURL = ""
 			}
 		}
 	})
 
+	// Subtitles are linked from the movie detail page
+	downloadCollector.OnHTML(`article.post-body a[href$=".srt"], article.post-body a[href*="subtitle"]`, func(e *colly.HTMLElement) {
+		movie := &((*movies)[GetMovieIndexFromCtx(e.Request)])
+		if movie.SubtitleLink != nil {
+			return
+		}
+		subtitleLink, err := url.Parse(e.Request.AbsoluteURL(e.Attr("href")))
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		movie.SubtitleLink = subtitleLink
+	})
+
 	// Update movie size
 	downloadCollector.OnHTML("div.file-size", func(e *colly.HTMLElement) {
-		(*movies)[getMovieIndexFromCtx(e.Request)].Size = strings.TrimSpace(e.ChildText("span.size-number"))
+		(*movies)[GetMovieIndexFromCtx(e.Request)].Size = strings.TrimSpace(e.ChildText("span.size-number"))
 	})
 
 	// Fetch Movie details from movie detail page
 	downloadCollector.OnHTML("article.post-body", func(e *colly.HTMLElement) {
-		movieIndex := getMovieIndexFromCtx(e.Request)
+		movieIndex := GetMovieIndexFromCtx(e.Request)
 		movie := &((*movies)[movieIndex])
 		description := e.ChildText("p")
 		if description != "" {
@@ -240,52 +264,65 @@ func (engine *NetNaijaEngine) updateDownloadProps(downloadCollector *colly.Colle
 
 	//for series or parts
 	downloadCollector.OnHTML("div.video-series-latest-episodes", func(inn *colly.HTMLElement) {
-		movie := &((*movies)[getMovieIndexFromCtx(inn.Request)])
+		movie := &((*movies)[GetMovieIndexFromCtx(inn.Request)])
 		movie.IsSeries = true
 		video_map := map[string]*url.URL{}
+		var episodes []Episode
 		inn.ForEach("a", func(num int, e *colly.HTMLElement) {
 			downloadLink, err := url.Parse(e.Attr("href"))
 			if err != nil {
-				log.Fatal(err)
+				log.Error(err)
+				return
 			}
 			downloadLink.Path = path.Join(downloadLink.Path, "download")
 			video_map[strconv.Itoa(num)] = downloadLink
+			// Episodes are listed in order, their titles may tell their season and number
+			title := strings.TrimSpace(e.Text)
+			season, number := ParseEpisode(title)
+			if season == 0 {
+				season = seriesSeason(movie.Title)
+			}
+			if number == 0 {
+				number = num + 1
+			}
+			episodes = append(episodes, Episode{Season: season, Number: number, Title: title, Link: downloadLink})
 		})
 		movie.SDownloadLink = video_map
+		movie.Seasons = GroupSeasons(episodes)
 	})
 }
 
 // List : list all the movies on a page
-func (engine *NetNaijaEngine) List(page int) SearchResult {
-	engine.mode = ListMode
+func (engine *NetNaijaEngine) List(ctx context.Context, page int) (SearchResult, error) {
+	req := engine.NewListRequest(page)
 	result := SearchResult{
 		Query: "List of Recent Uploads - Page " + strconv.Itoa(page),
 	}
 	pageParam := fmt.Sprintf("page/%v", strconv.Itoa(page))
-	engine.ListURL.Path = path.Join(engine.ListURL.Path, pageParam)
-	movies, err := Scrape(engine)
+	req.URL.Path = path.Join(req.URL.Path, pageParam)
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
 
 // Search : Searches netnaija for a particular query and return an array of movies
-func (engine *NetNaijaEngine) Search(param ...string) SearchResult {
+func (engine *NetNaijaEngine) Search(ctx context.Context, param ...string) (SearchResult, error) {
 	query := param[0]
-	engine.mode = SearchMode
+	req := engine.NewSearchRequest(query)
 	result := SearchResult{
 		Query: query,
 	}
-	q := engine.SearchURL.Query()
+	q := req.URL.Query()
 	q.Set("t", query)
 	q.Set("folder", "videos")
-	engine.SearchURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
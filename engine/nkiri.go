@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"path"
@@ -58,12 +59,12 @@ func (engine *NkiriEngine) String() string {
 	return fmt.Sprintf("%s (%s)", engine.Name, engine.BaseURL)
 }
 
-func (engine *NkiriEngine) getParseAttrs() (string, string, error) {
+func (engine *NkiriEngine) GetParseAttrs(req *Request) (string, string, error) {
 	var (
 		article string
 		main    string
 	)
-	switch engine.mode {
+	switch req.Mode {
 	case SearchMode:
 		article = "article"
 		main = "div.site-content"
@@ -71,17 +72,17 @@ func (engine *NkiriEngine) getParseAttrs() (string, string, error) {
 		main = "div.entries"
 		article = "article.blog-entry"
 	default:
-		return "", "", fmt.Errorf("Invalid mode %v", engine.mode)
+		return "", "", fmt.Errorf("Invalid mode %v", req.Mode)
 	}
 	return main, article, nil
 }
 
-func (engine *NkiriEngine) parseSingleMovie(el *colly.HTMLElement, index int) (Movie, error) {
+func (engine *NkiriEngine) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
 	// movie title identifier
 	yearRe := regexp.MustCompile(`\((.*)\)`)
 	removeCaratRe, err := regexp.Compile(`[^\w()]`)
 	if err != nil {
-		log.Fatal(err)
+		return Movie{}, err
 	}
 	movie := Movie{
 		Index:    index,
@@ -91,18 +92,18 @@ func (engine *NkiriEngine) parseSingleMovie(el *colly.HTMLElement, index int) (M
 	movie.CoverPhotoLink = el.ChildAttr("img", "src")
 	// Split with '|': Title at Index 0
 	titleSplit := strings.Split(el.ChildText("h2"), " | ")
-	if len(titleSplit) >= 1 {
-		movie.Title = removeCaratRe.ReplaceAllString(titleSplit[0], "_")
+	movie.Title = removeCaratRe.ReplaceAllString(titleSplit[0], "_")
+	if len(titleSplit) > 1 {
 		movie.Category = strings.TrimSuffix(strings.TrimPrefix(titleSplit[1], "Download"), "Movie")
 	}
 	//Fetch UploadDate for ListMode Items
-	if engine.mode == ListMode {
+	if req.Mode == ListMode {
 		movie.UploadDate = strings.TrimSpace(el.ChildText("div.blog-entry-date"))
 	}
 	//Fetch DownloadLink
 	downloadLink, err := url.Parse(el.ChildAttr("a", "href"))
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 	movie.DownloadLink = downloadLink
 	if movie.Title != "" {
@@ -117,29 +118,52 @@ func (engine *NkiriEngine) parseSingleMovie(el *colly.HTMLElement, index int) (M
 	return movie, nil
 }
 
-func (engine *NkiriEngine) updateDownloadProps(downloadCollector *colly.Collector, movies *[]Movie) {
+func (engine *NkiriEngine) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
 	sizeRe := regexp.MustCompile(`(\d.*)`)
 	downloadCollector.OnHTML("div.elementor-section-wrap", func(e *colly.HTMLElement) {
-		movieIndex := getMovieIndexFromCtx(e.Request)
+		movieIndex := GetMovieIndexFromCtx(e.Request)
 		movie := &((*movies)[movieIndex])
 		seriesMap := map[string]*url.URL{}
+		subtitleMap := map[string]*url.URL{}
+		var episodes []Episode
 		episode := 0
 		nextSection := false
 		e.ForEach("section.elementor-section", func(n int, inner *colly.HTMLElement) {
 			switch {
+			//Fetch Subtitles of the Movie or of an Episode e.g Download Episode 1 Subtitle
+			case strings.HasPrefix(inner.ChildText("span.elementor-button-text"), "Download") &&
+				strings.Contains(inner.ChildText("span.elementor-button-text"), "Subtitle"):
+				subtitleLink, err := url.Parse(inner.ChildAttr("div.elementor-button-wrapper > a", "href"))
+				if err != nil {
+					log.Error(err)
+					return
+				}
+				if _, number := ParseEpisode(inner.ChildText("span.elementor-button-text")); number > 0 {
+					subtitleMap[strconv.Itoa(number)] = subtitleLink
+				} else {
+					movie.SubtitleLink = subtitleLink
+				}
 			//Fetch Download Link For Series
 			case strings.HasPrefix(inner.ChildText("span.elementor-button-text"), "Download Episode"):
 				episode++
 				downloadLink, err := url.Parse(inner.ChildAttr("div.elementor-button-wrapper > a", "href"))
 				if err != nil {
-					log.Fatal(err)
+					log.Error(err)
+					return
 				}
 				seriesMap[strconv.Itoa(episode)] = downloadLink
+				episodes = append(episodes, Episode{
+					Season: seriesSeason(movie.Title),
+					Number: episode,
+					Title:  strings.TrimPrefix(strings.TrimSpace(inner.ChildText("span.elementor-button-text")), "Download "),
+					Link:   downloadLink,
+				})
 			//Fetch DownloadLink For Movies
 			case strings.HasPrefix(inner.ChildText("span.elementor-button-text"), "Download Movie"):
 				downloadLink, err := url.Parse(inner.ChildAttr("div.elementor-button-wrapper > a", "href"))
 				if err != nil {
-					log.Fatal(err)
+					log.Error(err)
+					return
 				}
 				movie.DownloadLink = downloadLink
 			}
@@ -161,48 +185,62 @@ func (engine *NkiriEngine) updateDownloadProps(downloadCollector *colly.Collecto
 			}
 		})
 		if len(seriesMap) > 0 {
+			for i := range episodes {
+				episodes[i].Subtitle = subtitleMap[strconv.Itoa(episodes[i].Number)]
+			}
 			movie.IsSeries = true
 			movie.SDownloadLink = seriesMap
+			movie.SubtitleLinks = subtitleMap
+			movie.Seasons = GroupSeasons(episodes)
 		}
 	})
 }
 
 // List : list all the movies on a page
-func (engine *NkiriEngine) List(page int) SearchResult {
-	engine.mode = ListMode
+func (engine *NkiriEngine) List(ctx context.Context, page int) (SearchResult, error) {
 	result := SearchResult{
 		Query: "List of Recent Uploads - Page " + strconv.Itoa(page),
 	}
 	pageParam := fmt.Sprintf("page/%v", strconv.Itoa(page))
 	movies := []Movie{}
-	listCategoryPath := engine.ListURL.Path
+	var listErr error
 	for _, category := range engine.ListCategories {
-		engine.ListURL.Path = path.Join(listCategoryPath, category, pageParam)
-		listResult, err := Scrape(engine)
+		req := engine.NewListRequest(page)
+		req.URL.Path = path.Join(req.URL.Path, category, pageParam)
+		listResult, err := Scrape(ctx, engine, req)
 		if err != nil {
-			log.Fatal(err)
+			if ctx.Err() != nil {
+				return result, err
+			}
+			// A failing category should not hide the results of the others
+			log.Errorf("Could not list category %s: %v", category, err)
+			listErr = err
+			continue
 		}
 		movies = append(movies, listResult...)
 	}
+	if len(movies) == 0 && listErr != nil {
+		return result, listErr
+	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
 
 // Search : Searches nkiri for a particular query and return an array of movies
-func (engine *NkiriEngine) Search(param ...string) SearchResult {
+func (engine *NkiriEngine) Search(ctx context.Context, param ...string) (SearchResult, error) {
 	query := param[0]
-	engine.mode = SearchMode
+	req := engine.NewSearchRequest(query)
 	result := SearchResult{
 		Query: query,
 	}
-	q := engine.SearchURL.Query()
+	q := req.URL.Query()
 	q.Set("s", query)
 	q.Set("post_type", "post")
-	engine.SearchURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Years written as (2017) or [2017]
+	bracketYearRe = regexp.MustCompile(`[(\[]((?:19|20)\d{2})[)\]]`)
+	// Years at the end of a title e.g Jumanji 2017
+	trailingYearRe = regexp.MustCompile(`\s((?:19|20)\d{2})\s*$`)
+	// Anything within brackets e.g (2017), [Korean]
+	bracketsRe = regexp.MustCompile(`[(\[][^)\]]*[)\]]`)
+	qualityRe  = regexp.MustCompile(`(?i)\b(2160p|1080p|720p|480p|360p|4k|hdrip|hdcam|hdtv|cam|bluray|brrip|web-?dl|webrip|dvdrip|x264|x265|hevc|mkv|mp4)\b`)
+	// Anything that is not a letter or a number
+	separatorRe = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+)
+
+// Normalized : the canonical details of a movie used to compare movies from different engines
+type Normalized struct {
+	Title   string // lowercase title without year, quality or punctuation
+	Year    int
+	Quality string
+}
+
+// MovieGroup : equivalent movies from one or more engines
+type MovieGroup struct {
+	Title   string  // Display title of the movie
+	Year    int     // Year of the movie if known
+	Quality string  // Best known quality of the movie
+	Score   float64 // Relevance of the movie to the query
+	Movies  []Movie // The equivalent movies, each with its own Source and DownloadLink
+
+	normalized []Normalized
+}
+
+// Normalize : extract the canonical title, year and quality of a movie
+func Normalize(movie Movie) Normalized {
+	n := Normalized{
+		Year:    movie.Year,
+		Quality: strings.ToLower(movie.Quality),
+	}
+	title := strings.ReplaceAll(movie.Title, "_", " ")
+	if year := bracketYearRe.FindStringSubmatch(title); len(year) > 1 {
+		n.Year, _ = strconv.Atoi(year[1])
+	} else if year := trailingYear(title); year != 0 && n.Year == 0 {
+		n.Year = year
+	}
+	if quality := qualityRe.FindString(title); quality != "" {
+		n.Quality = strings.ToLower(quality)
+	}
+	n.Title = canonicalTitle(title)
+	return n
+}
+
+// Return the year at the end of a title if any
+// Numbers in the future are part of the title e.g Blade Runner 2049
+func trailingYear(title string) int {
+	if match := trailingYearRe.FindStringSubmatch(title); len(match) > 1 {
+		if year, _ := strconv.Atoi(match[1]); year <= time.Now().Year()+1 {
+			return year
+		}
+	}
+	return 0
+}
+
+// strip years, quality and brackets from a title keeping its case
+func cleanTitle(title string) string {
+	title = strings.ReplaceAll(title, "_", " ")
+	title = bracketsRe.ReplaceAllString(title, "")
+	title = qualityRe.ReplaceAllString(title, "")
+	if trailingYear(title) != 0 {
+		title = trailingYearRe.ReplaceAllString(title, "")
+	}
+	return strings.Join(strings.Fields(title), " ")
+}
+
+func canonicalTitle(title string) string {
+	title = strings.ToLower(cleanTitle(title))
+	return strings.TrimSpace(separatorRe.ReplaceAllString(title, " "))
+}
+
+// Matches : reports whether both describe the same movie
+// Titles must be equal, or one must start with the other when both years are known and equal,
+// so that "Jumanji (2017)" matches "Jumanji: Welcome to the Jungle [2017]"
+func (n Normalized) Matches(other Normalized) bool {
+	if n.Title == "" || other.Title == "" {
+		return false
+	}
+	if n.Year != 0 && other.Year != 0 && n.Year != other.Year {
+		return false
+	}
+	if n.Title == other.Title {
+		return true
+	}
+	if n.Year == 0 || other.Year == 0 {
+		return false
+	}
+	return strings.HasPrefix(n.Title+" ", other.Title+" ") || strings.HasPrefix(other.Title+" ", n.Title+" ")
+}
+
+// Sources : names of the engines the movie is available from
+func (g *MovieGroup) Sources() []string {
+	var sources []string
+	for _, movie := range g.Movies {
+		if !containsString(sources, movie.Source) {
+			sources = append(sources, movie.Source)
+		}
+	}
+	return sources
+}
+
+func (g *MovieGroup) matches(n Normalized) bool {
+	for _, member := range g.normalized {
+		if member.Matches(n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *MovieGroup) add(movie Movie, n Normalized) {
+	// The longest title is usually the most descriptive
+	if len(n.Title) > len(canonicalTitle(g.Title)) {
+		g.Title = cleanTitle(movie.Title)
+	}
+	if g.Year == 0 {
+		g.Year = n.Year
+	}
+	if g.Quality == "" {
+		g.Quality = n.Quality
+	}
+	g.Movies = append(g.Movies, movie)
+	g.normalized = append(g.normalized, n)
+}
+
+// Group : Group equivalent movies of the result and rank the groups by relevance to the query
+// Groups with the same relevance keep the order in which their first movie was returned
+func (s *SearchResult) Group() []MovieGroup {
+	var groups []MovieGroup
+	for _, movie := range s.Movies {
+		n := Normalize(movie)
+		found := false
+		for i := range groups {
+			if groups[i].matches(n) {
+				groups[i].add(movie, n)
+				found = true
+				break
+			}
+		}
+		if !found {
+			group := MovieGroup{}
+			group.add(movie, n)
+			groups = append(groups, group)
+		}
+	}
+
+	query := canonicalTitle(s.Query)
+	for i := range groups {
+		groups[i].Score = relevance(query, canonicalTitle(groups[i].Title))
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Score != groups[j].Score {
+			return groups[i].Score > groups[j].Score
+		}
+		// Movies available from more engines are more likely to be what was searched for
+		return len(groups[i].Sources()) > len(groups[j].Sources())
+	})
+	return groups
+}
+
+// relevance : score how well a canonical title matches a canonical query
+func relevance(query, title string) float64 {
+	queryWords := strings.Fields(query)
+	if len(queryWords) == 0 || title == "" {
+		return 0
+	}
+	if query == title {
+		return 2
+	}
+	titleWords := strings.Fields(title)
+	matched := 0
+	for _, word := range queryWords {
+		if containsString(titleWords, word) {
+			matched++
+		}
+	}
+	score := float64(matched) / float64(len(queryWords))
+	if strings.HasPrefix(title+" ", query+" ") {
+		score += 0.5
+	}
+	// Prefer titles with fewer words that were not searched for
+	return score - 0.01*float64(len(titleWords)-matched)
+}
+
+// MatchesQuery : reports whether title has every word of query, regardless of case, years,
+// quality and punctuation
+func MatchesQuery(query, title string) bool {
+	titleWords := strings.Fields(canonicalTitle(title))
+	for _, word := range strings.Fields(canonicalTitle(query)) {
+		if !containsString(titleWords, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
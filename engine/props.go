@@ -6,14 +6,16 @@ import (
 )
 
 // Props : The scraping engine Properties and description about the engine (e.g NetNaijaEngine)
+// Props are shared by every call on the engine and must not be modified once the engine is in use,
+// state of a single call lives in a Request
 type Props struct {
 	// Struct attributes
 	Name        string
-	BaseURL     *url.URL // The Base URL for the engine
-	SearchURL   *url.URL // URL for searching
-	ListURL     *url.URL // URL to return movie lists
+	BaseURL     *url.URL   // The Base URL for the engine
+	SearchURL   *url.URL   // URL for searching
+	ListURL     *url.URL   // URL to return movie lists
+	Mirrors     []*url.URL // Base URLs tried in order when the site cannot be reached
 	Description string
-	mode        Mode // The mode of the operations (list, search)
 }
 
 // PropsJSON : JSON structure of all downloadable movies
@@ -22,6 +24,15 @@ type PropsJSON struct {
 	BaseURL   string
 	SearchURL string
 	ListURL   string
+	Mirrors   []string
+}
+
+// Request : The state of a single Search or List call on an engine
+type Request struct {
+	Mode  Mode     // The mode of the operation (list, search)
+	Query string   // The query being searched for in SearchMode
+	Page  int      // The page to be returned
+	URL   *url.URL // The URL to be scraped
 }
 
 // MarshalJSON Props structure to return from api
@@ -32,17 +43,48 @@ func (p *Props) MarshalJSON() ([]byte, error) {
 		SearchURL: p.SearchURL.String(),
 		ListURL:   p.ListURL.String(),
 	}
+	for _, mirror := range p.Mirrors {
+		props.Mirrors = append(props.Mirrors, mirror.String())
+	}
 
 	return json.Marshal(props)
 }
 
-func (p *Props) getParseURL() *url.URL {
-	if p.mode == SearchMode {
-		return p.SearchURL
+// NewSearchRequest : create a request for searching with a copy of the SearchURL
+func (p *Props) NewSearchRequest(query string) *Request {
+	return &Request{
+		Mode:  SearchMode,
+		Query: query,
+		Page:  1,
+		URL:   copyURL(p.SearchURL),
+	}
+}
+
+// NewListRequest : create a request for listing with a copy of the ListURL
+func (p *Props) NewListRequest(page int) *Request {
+	return &Request{
+		Mode: ListMode,
+		Page: page,
+		URL:  copyURL(p.ListURL),
 	}
-	return p.ListURL
 }
 
-func (p *Props) getName() string {
+// GetName : the name of the engine
+func (p *Props) GetName() string {
 	return p.Name
 }
+
+// GetProps : the properties of the engine, used to override them from config
+func (p *Props) GetProps() *Props {
+	return p
+}
+
+// copyURL : Return a copy of u that can be modified without affecting u
+func copyURL(u *url.URL) *url.URL {
+	c := *u
+	if u.User != nil {
+		user := *u.User
+		c.User = &user
+	}
+	return &c
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Factory : creates a new instance of an engine
+type Factory func() Engine
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+func init() {
+	Register("netnaija", func() Engine { return NewNetNaijaEngine() })
+	Register("fzmovies", func() Engine { return NewFzEngine() })
+	Register("besthdmovies", func() Engine { return NewBestHDEngine() })
+	Register("tvseries", func() Engine { return NewTvSeriesEngine() })
+	Register("mycoolmoviez", func() Engine { return NewMyCoolMoviezEngine() })
+	Register("coolmoviez", func() Engine { return NewCoolMoviezEngine() })
+	Register("animeout", func() Engine { return NewAnimeOutEngine() })
+	Register("takanimelist", func() Engine { return NewTakanimeListEngine() })
+	Register("kdramahood", func() Engine { return NewKDramaHoodEngine() })
+	Register("nkiri", func() Engine { return NewNkiriEngine() })
+}
+
+// Register : make an engine available under name (case insensitive)
+// It is meant to be called from the init function of the package implementing the engine.
+// Register panics if factory is nil or an engine is already registered under name
+func Register(name string, factory Factory) {
+	register(name, factory, false)
+}
+
+// register : add factory to the registry, replacing an engine registered under name if replace is set
+func register(name string, factory Factory, replace bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	name = strings.ToLower(name)
+	if factory == nil {
+		panic("engine: Register factory is nil for " + name)
+	}
+	if _, dup := registry[name]; dup {
+		if !replace {
+			panic("engine: Register called twice for " + name)
+		}
+		log.Infof("Engine %s replaced from config", name)
+	}
+	registry[name] = factory
+}
+
+// EngineNames : Returns the sorted names of all registered engines
+func EngineNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetEngines : Returns all the usable engines in the application
+// Engines are configured from config and the environment, see Configure
+func GetEngines() map[string]Engine {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	engines := make(map[string]Engine)
+	for name, factory := range registry {
+		engines[name] = Configure(name, factory())
+	}
+	return engines
+}
+
+// GetEngine : Return an engine
+func GetEngine(engine string) (Engine, error) {
+	registryMu.RLock()
+	factory := registry[strings.ToLower(engine)]
+	registryMu.RUnlock()
+	if factory == nil {
+		return nil, fmt.Errorf("Engine %s Does not exist", engine)
+	}
+	return Configure(strings.ToLower(engine), factory()), nil
+}
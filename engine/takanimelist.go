@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"path"
@@ -54,9 +55,9 @@ func (engine *TakanimeList) String() string {
 	return st
 }
 
-func (engine *TakanimeList) getParseAttrs() (string, string, error) {
+func (engine *TakanimeList) GetParseAttrs(req *Request) (string, string, error) {
 	var main, section string
-	switch engine.mode {
+	switch req.Mode {
 	case SearchMode:
 		main = "main.site-main"
 		section = "article.post"
@@ -68,14 +69,14 @@ func (engine *TakanimeList) getParseAttrs() (string, string, error) {
 	return main, section, nil
 }
 
-func (engine *TakanimeList) parseSingleMovie(el *colly.HTMLElement, index int) (Movie, error) {
+func (engine *TakanimeList) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
 	movie := Movie{
 		Index:    index,
 		IsSeries: true,
 		Source:   engine.Name,
 		Size:     "---MB",
 	}
-	if engine.mode == ListMode {
+	if req.Mode == ListMode {
 		movie.Title = strings.TrimSpace(el.ChildText("div.title"))
 		movie.CoverPhotoLink = el.ChildAttr("div.thumbnail-image", "data-img")
 		movie.Description = strings.TrimSpace(el.ChildText("div.excerpt"))
@@ -87,7 +88,7 @@ func (engine *TakanimeList) parseSingleMovie(el *colly.HTMLElement, index int) (
 	downloadLink, err := url.Parse(el.Request.AbsoluteURL(el.ChildAttr("a", "href")))
 
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 	log.Debug(movie.Title)
 	movie.DownloadLink = downloadLink
@@ -102,7 +103,9 @@ func retrieveSingle(downloadCollector *colly.Collector, initialLink string) stri
 		re := regexp.MustCompile(`window.open\(\.+\)`)
 		stringsub := re.FindStringSubmatch(e.Text)
 		log.Debug(stringsub)
-		finalLink = stringsub[0]
+		if len(stringsub) > 0 {
+			finalLink = stringsub[0]
+		}
 	})
 	if !strings.HasSuffix(strings.ToLower(initialLink), ".mkv") && !strings.HasSuffix(strings.ToLower(initialLink), ".mp4") {
 		downloadCollector.Visit(initialLink)
@@ -112,56 +115,76 @@ func retrieveSingle(downloadCollector *colly.Collector, initialLink string) stri
 	return finalLink
 }
 
-func (engine *TakanimeList) updateDownloadProps(downloadCollector *colly.Collector, movies *[]Movie) {
+func (engine *TakanimeList) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
 	internaldownloadCollector := downloadCollector.Clone()
 	downloadCollector.OnHTML("div.entry-content", func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		episodeMap := map[string]*url.URL{}
 		linkArray := e.ChildAttrs("a", "href")
 		titleArray := e.ChildTexts("a")
 
+		// Episodes may be available in several qualities e.g Episode 01 (480p) and Episode 01 (720p)
+		variants := map[string][]Variant{}
 		for i := range linkArray {
 			movie.DownloadLink, _ = url.Parse(linkArray[i])
 			finalLink := retrieveSingle(internaldownloadCollector, linkArray[i])
 			downloadLink, _ := url.Parse(finalLink)
 			if strconv.Itoa(i) != "" && downloadLink.String() != "" {
 				episodeMap[titleArray[i]] = downloadLink
+				quality := resolutionRe.FindString(titleArray[i])
+				if quality == "" {
+					quality = resolutionRe.FindString(finalLink)
+				}
+				title := strings.Trim(resolutionRe.ReplaceAllString(titleArray[i], ""), " ()[]-|")
+				variants[title] = append(variants[title], NewVariant(quality, "", downloadLink))
+			}
+		}
+		episodeLinks := map[string]*url.URL{}
+		for title, episodeVariants := range variants {
+			best, _ := SelectVariant(episodeVariants, "", 0)
+			episodeLinks[title] = best.Link
+		}
+		episodes := EpisodesFromLinks(movie.Title, episodeLinks, nil)
+		for i := range episodes {
+			if episodeVariants := variants[episodes[i].Title]; len(episodeVariants) > 1 {
+				episodes[i].Variants = episodeVariants
 			}
 		}
 		movie.SDownloadLink = episodeMap
+		movie.Seasons = GroupSeasons(episodes)
 	})
 }
 
 // List : list all the movies on a page
-func (engine *TakanimeList) List(page int) SearchResult {
-	engine.mode = ListMode
+func (engine *TakanimeList) List(ctx context.Context, page int) (SearchResult, error) {
+	req := engine.NewListRequest(page)
 	result := SearchResult{
 		Query: "List of Recent Uploads - Page " + strconv.Itoa(page),
 	}
 	pageParam := fmt.Sprintf("page/%v", strconv.Itoa(page))
-	engine.ListURL.Path = path.Join(engine.ListURL.Path, pageParam)
-	movies, err := Scrape(engine)
+	req.URL.Path = path.Join(req.URL.Path, pageParam)
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
 
 // Search : Searches takanimelist for a particular query and return an array of movies
-func (engine *TakanimeList) Search(param ...string) SearchResult {
+func (engine *TakanimeList) Search(ctx context.Context, param ...string) (SearchResult, error) {
 	query := param[0]
-	engine.mode = SearchMode
+	req := engine.NewSearchRequest(query)
 	result := SearchResult{
 		Query: query,
 	}
-	q := engine.SearchURL.Query()
+	q := req.URL.Query()
 	q.Set("s", query)
-	engine.SearchURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
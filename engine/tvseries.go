@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"regexp"
@@ -53,11 +54,11 @@ func (engine *TvSeriesEngine) String() string {
 	return st
 }
 
-func (engine *TvSeriesEngine) getParseAttrs() (string, string, error) {
+func (engine *TvSeriesEngine) GetParseAttrs(req *Request) (string, string, error) {
 	return "body", "div.mainbox", nil
 }
 
-func (engine *TvSeriesEngine) parseSingleMovie(el *colly.HTMLElement, index int) (Movie, error) {
+func (engine *TvSeriesEngine) ParseSingleMovie(req *Request, el *colly.HTMLElement, index int) (Movie, error) {
 	movie := Movie{
 		Index:    index,
 		IsSeries: false,
@@ -65,7 +66,7 @@ func (engine *TvSeriesEngine) parseSingleMovie(el *colly.HTMLElement, index int)
 	}
 	cover, err := url.Parse(el.Request.AbsoluteURL(el.ChildAttr("img", "src")))
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 	movie.CoverPhotoLink = cover.String()
 	titleAndDescription := el.ChildTexts("small")
@@ -82,17 +83,17 @@ func (engine *TvSeriesEngine) parseSingleMovie(el *colly.HTMLElement, index int)
 	downloadLink, err := url.Parse(link + "&ftype=2")
 
 	if err != nil {
-		log.Fatal(err)
+		return movie, err
 	}
 
 	movie.DownloadLink = downloadLink
 	return movie, nil
 }
 
-func (engine *TvSeriesEngine) updateDownloadProps(downloadCollector *colly.Collector, movies *[]Movie) {
+func (engine *TvSeriesEngine) UpdateDownloadProps(ctx context.Context, downloadCollector *colly.Collector, movies *[]Movie) {
 	// For listing movies and retrieving the most recently updated episode
 	downloadCollector.OnHTML("div[itemprop=episode]", func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		if len(e.ChildTexts("b")) > 1 {
 			movie.Title = e.ChildTexts("b")[0]
 		}
@@ -104,17 +105,17 @@ func (engine *TvSeriesEngine) updateDownloadProps(downloadCollector *colly.Colle
 			link := e.Request.AbsoluteURL(e.ChildAttr("a", "href")) + "&ftype=2" 	
 			downloadLink, err := url.Parse(link)
 			if err != nil {
-				log.Fatal(err)
-			} else {
-				movie.DownloadLink = downloadLink
+				log.Error(err)
+				return
 			}
+			movie.DownloadLink = downloadLink
 			downloadCollector.Visit(downloadLink.String())
 		}
 	})
 
 	// // Update movie download link if ul.downloadlinks on page
 	// downloadCollector.OnHTML("a[id=dlink2]", func(e *colly.HTMLElement) {
-	// 	movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+	// 	movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 	// 	link := e.Request.AbsoluteURL(e.Attr("href")) 	
 	// 	downloadLink, err := url.Parse(link)
 	// 	if err != nil {
@@ -125,81 +126,103 @@ func (engine *TvSeriesEngine) updateDownloadProps(downloadCollector *colly.Colle
 	// 	downloadCollector.Visit(downloadLink.String())
 	// })
 
-	for _, iden := range [...]string{ "a[id=dlink3]",  "a[id=dlink4]", "a[id=dlink2]"} {
-		// Update movie download link if ul.downloadlinks on page
+	// Every file the episode is available as has its own dlink
+	for _, iden := range [...]string{"a[id=dlink2]", "a[id=dlink3]", "a[id=dlink4]"} {
 		downloadCollector.OnHTML(iden, func(e *colly.HTMLElement) {
-			movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
-			link := e.Request.AbsoluteURL(e.Attr("href")) 	
+			movieIndex := GetMovieIndexFromCtx(e.Request)
+			movie := &(*movies)[movieIndex]
+			link := e.Request.AbsoluteURL(e.Attr("href"))
 			downloadLink, err := url.Parse(link)
 			if err != nil {
-				log.Fatal(err)
-			} else {
+				log.Error(err)
+				return
+			}
+			quality := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(e.Text), "Download "), "File")
+			variant := movie.AddVariant(NewVariant(quality, "", downloadLink))
+			if variant == 0 {
 				movie.DownloadLink = downloadLink
 			}
-			if !(strings.HasSuffix(movie.DownloadLink.String(), "mp4") || strings.HasSuffix(movie.DownloadLink.String(), "mp4")){
-				downloadCollector.Visit(downloadLink.String())
+			if !(strings.HasSuffix(downloadLink.Path, "mp4") || strings.HasSuffix(downloadLink.Path, "mkv")) {
+				if err = variantRequest(downloadCollector, movieIndex, variant, downloadLink.String()); err != nil {
+					log.Debug(err)
+				}
 			}
 		})
 	}
 
 	// Update Download Link if "Download" HTML on page
 	downloadCollector.OnHTML("div.filedownload", func(e *colly.HTMLElement) {
-		movie := &(*movies)[getMovieIndexFromCtx(e.Request)]
+		movie := &(*movies)[GetMovieIndexFromCtx(e.Request)]
 		re := regexp.MustCompile(`(.* MB)`)
-		size := re.FindStringSubmatch(e.ChildText("textcolor2"))[0]
-		if e.ChildAttr("a[id=flink1]", "href") !=  "" {
-			downloadLink, err := url.Parse(e.ChildAttr("a[id=flink1]", "href"))
-			if err == nil && downloadLink.String() != "" {
-				movie.DownloadLink = downloadLink
+		link := e.ChildAttr("a[id=flink1]", "href")
+		if link == "" {
+			link = e.ChildAttr("input[name=filelink]", "value")
+		}
+		updateVariant(movie, e.Request, func(variant *Variant) {
+			if size := re.FindStringSubmatch(e.ChildText("textcolor2")); len(size) > 0 {
+				variant.Size = size[0]
 			}
-		} else {
-			downloadLink, err := url.Parse(e.ChildAttr("input[name=filelink]", "value"))
+			downloadLink, err := url.Parse(link)
 			if err == nil && downloadLink.String() != "" {
-				movie.DownloadLink = downloadLink
+				variant.Link = downloadLink
+			}
+		})
+		// Every movie is an episode e.g Devs - S01E08
+		if season, number := ParseEpisode(movie.Title); number > 0 {
+			if season == 0 {
+				season = 1
 			}
+			movie.Seasons = GroupSeasons([]Episode{{
+				Season:   season,
+				Number:   number,
+				Title:    movie.Title,
+				Link:     movie.DownloadLink,
+				Size:     movie.Size,
+				Variants: movie.Variants,
+			}})
 		}
-		movie.Size = size
 	})
 }
 
 // List : list all the movies on a page
-func (engine *TvSeriesEngine) List(page int) SearchResult {
-	engine.mode = ListMode
+func (engine *TvSeriesEngine) List(ctx context.Context, page int) (SearchResult, error) {
+	req := engine.NewListRequest(page)
 	result := SearchResult{
 		Query: "Series From A to Z latest episode each - Page " + strconv.Itoa(page),
 	}
-	q := engine.ListURL.Query()
+	q := req.URL.Query()
 	q.Set("alpha", "AtoZ")
 	q.Set("pg", strconv.Itoa(page))
-	engine.ListURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
 
 // Search : Searches tvseries for a particular query and return an array of movies
-func (engine *TvSeriesEngine) Search(param ...string) SearchResult {
+func (engine *TvSeriesEngine) Search(ctx context.Context, param ...string) (SearchResult, error) {
 	query := param[0]
-	engine.mode = SearchMode
+	req := engine.NewSearchRequest(query)
 	result := SearchResult{
 		Query: query,
 	}
-	q := engine.SearchURL.Query()
+	q := req.URL.Query()
 	q.Set("search", query)
 	q.Set("beginsearch", "Search")
 	q.Set("vsearch", "")
 	q.Set("by", "episodes")
 	if len(param) > 1 {
 		q.Set("pg", param[1])
+		req.Page, _ = strconv.Atoi(param[1])
 	}
-	engine.SearchURL.RawQuery = q.Encode()
-	movies, err := Scrape(engine)
+	req.URL.RawQuery = q.Encode()
+	movies, err := Scrape(ctx, engine, req)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
 	result.Movies = movies
-	return result
+	return result, nil
 }
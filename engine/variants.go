@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Variant : one of the files a movie is available as, e.g in 480p and 720p
+type Variant struct {
+	Quality   string // e.g 720p or High MP4
+	Container string // Extension of the file e.g mp4 or mkv
+	Size      string
+	Link      *url.URL
+}
+
+// VariantJSON : JSON structure of a variant
+type VariantJSON struct {
+	Variant
+	Link string
+}
+
+// MarshalJSON Json structure to return from api
+func (v *Variant) MarshalJSON() ([]byte, error) {
+	variant := VariantJSON{Variant: *v}
+	if v.Link != nil {
+		variant.Link = v.Link.String()
+	}
+	return json.Marshal(variant)
+}
+
+func (v Variant) String() string {
+	details := []string{}
+	for _, detail := range []string{v.Quality, v.Container, v.Size} {
+		if detail != "" && !containsString(details, detail) {
+			details = append(details, detail)
+		}
+	}
+	return strings.Join(details, " ")
+}
+
+var (
+	resolutionRe = regexp.MustCompile(`(?i)\b(\d{3,4})p\b`)
+	byteSizeRe   = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*([KMGT]?)i?B\b`)
+)
+
+// Resolution : the vertical resolution of the variant e.g 720 for 720p, zero if unknown
+func (v Variant) Resolution() int {
+	if match := resolutionRe.FindStringSubmatch(v.Quality); match != nil {
+		resolution, _ := strconv.Atoi(match[1])
+		return resolution
+	}
+	if strings.Contains(strings.ToLower(v.Quality), "4k") {
+		return 2160
+	}
+	return 0
+}
+
+// Bytes : the size of the variant in bytes, zero if unknown
+func (v Variant) Bytes() int64 {
+	return ParseSize(v.Size)
+}
+
+// ParseSize : the number of bytes in a human readable size e.g 812 MB or 1.13GB, zero if it
+// cannot be parsed
+func ParseSize(size string) int64 {
+	match := byteSizeRe.FindStringSubmatch(size)
+	if match == nil {
+		return 0
+	}
+	n, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0
+	}
+	exp := strings.Index("KMGT", strings.ToUpper(match[2])) + 1
+	return int64(n * math.Pow(1024, float64(exp)))
+}
+
+// NewVariant : a variant at link, with its container found from the extension of the link
+func NewVariant(quality, size string, link *url.URL) Variant {
+	variant := Variant{Quality: strings.TrimSpace(quality), Size: strings.TrimSpace(size), Link: link}
+	variant.Container = container(link)
+	return variant
+}
+
+// container : the container of the media file at link e.g mp4, empty if link is not a media file
+func container(link *url.URL) string {
+	if link == nil {
+		return ""
+	}
+	ext := strings.ToLower(path.Ext(link.Path))
+	if !containsString(mediaExtensions, ext) {
+		return ""
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
+// AddVariant : add a variant of the movie and return its index
+func (m *Movie) AddVariant(variant Variant) int {
+	m.Variants = append(m.Variants, variant)
+	return len(m.Variants) - 1
+}
+
+// variantRequest : follow a variant of the movie at movieIndex to link, keeping track of which
+// variant the pages that lead to its file belong to
+func variantRequest(c *colly.Collector, movieIndex, variant int, link string) error {
+	ctx := colly.NewContext()
+	ctx.Put("movieIndex", strconv.Itoa(movieIndex))
+	ctx.Put("variant", strconv.Itoa(variant))
+	return c.Request("GET", link, nil, ctx, nil)
+}
+
+// updateVariant : update the variant the request was made for with fn
+// The download link and size of a movie follow its first variant, or are the only ones updated
+// when the request was not made for a variant
+func updateVariant(movie *Movie, r *colly.Request, fn func(variant *Variant)) {
+	index, err := strconv.Atoi(r.Ctx.Get("variant"))
+	if err != nil || index < 0 || index >= len(movie.Variants) {
+		variant := Variant{Link: movie.DownloadLink, Size: movie.Size}
+		fn(&variant)
+		movie.DownloadLink, movie.Size = variant.Link, variant.Size
+		return
+	}
+	variant := &movie.Variants[index]
+	fn(variant)
+	if ext := container(variant.Link); ext != "" {
+		variant.Container = ext
+	}
+	if index == 0 {
+		movie.DownloadLink, movie.Size = variant.Link, variant.Size
+	}
+}
+
+// SelectVariant : the variant of quality e.g 720p that is at most maxSize bytes
+// Without an exact match, the best variant below the quality is preferred, then the best one.
+// Variants of unknown size are not excluded by maxSize, which is ignored when zero
+func SelectVariant(variants []Variant, quality string, maxSize int64) (Variant, error) {
+	var candidates []Variant
+	for _, variant := range variants {
+		if maxSize <= 0 || variant.Bytes() <= maxSize {
+			candidates = append(candidates, variant)
+		}
+	}
+	if len(candidates) == 0 {
+		return Variant{}, fmt.Errorf("no variant is smaller than %d bytes", maxSize)
+	}
+	quality = strings.ToLower(strings.TrimSpace(quality))
+	if quality != "" {
+		for _, variant := range candidates {
+			if strings.Contains(strings.ToLower(variant.Quality), quality) {
+				return variant, nil
+			}
+		}
+	}
+	wanted := Variant{Quality: quality}.Resolution()
+	best := -1
+	for i, variant := range candidates {
+		if wanted > 0 && variant.Resolution() > wanted {
+			continue
+		}
+		if best < 0 || better(variant, candidates[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		// Every variant is above the quality, take the lowest
+		for i, variant := range candidates {
+			if best < 0 || variant.Resolution() < candidates[best].Resolution() {
+				best = i
+			}
+		}
+	}
+	return candidates[best], nil
+}
+
+// better : reports whether a has a higher resolution than b, or is larger when resolutions are unknown
+func better(a, b Variant) bool {
+	if a.Resolution() != b.Resolution() {
+		return a.Resolution() > b.Resolution()
+	}
+	return a.Bytes() > b.Bytes()
+}
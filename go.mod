@@ -9,7 +9,6 @@ require (
 	github.com/chromedp/chromedp v0.5.3
 	github.com/gocolly/colly/v2 v2.1.0
 	github.com/gorilla/handlers v1.5.1
-	github.com/iawia002/annie v0.0.0-20200720035628-03c160f28b4b
 	github.com/manifoldco/promptui v0.7.0
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/sirupsen/logrus v1.6.0
@@ -17,5 +16,6 @@ require (
 	github.com/spf13/cobra v1.0.0
 	github.com/spf13/viper v1.7.0
 	github.com/tebeka/selenium v0.9.9
-	golang.org/x/sys v0.3.0 // indirect
+	golang.org/x/sys v0.3.0
+	gopkg.in/yaml.v2 v2.2.4
 )
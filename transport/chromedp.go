@@ -97,7 +97,11 @@ func (t *ChromeDpTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 	log.Debug("Set Headers for page ", r.URL.String())
 
 	// Check if CloudFlare blocker exists
-	resp, err := http.Get(r.URL.String())
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, r.URL.String(), nil)
+	if err != nil {
+		return &http.Response{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return &http.Response{}, err
 	}
@@ -0,0 +1,31 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+)
+
+// ContextTransport : binds every request to a context so they are cancelled along with it
+type ContextTransport struct {
+	upstream http.RoundTripper
+	ctx      context.Context
+}
+
+// NewContextTransport : initialize a transport that cancels requests once ctx is done
+func NewContextTransport(ctx context.Context, upstream http.RoundTripper) *ContextTransport {
+	if upstream == nil {
+		upstream = http.DefaultTransport
+	}
+	return &ContextTransport{
+		upstream: upstream,
+		ctx:      ctx,
+	}
+}
+
+// RoundTrip : extends the RoundTrip API for usage as a colly transport
+func (t *ContextTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := t.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return t.upstream.RoundTrip(r.WithContext(t.ctx))
+}
@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Characters that are not safe in fixture file names
+var unsafeFixtureRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// FixtureName : the name of the file a page is recorded to, derived from its method and url
+// e.g GET https://www.fzmovies.net/csearch.php?searchname=jumanji is recorded to
+// www.fzmovies.net_csearch.php_searchname_jumanji.html
+func FixtureName(method string, u *url.URL) string {
+	name := u.Host + u.EscapedPath()
+	if method != "" && method != http.MethodGet {
+		name = method + "_" + name
+	}
+	if u.RawQuery != "" {
+		// Sort params so that the name does not depend on their order
+		name += "?" + u.Query().Encode()
+	}
+	name = strings.Trim(unsafeFixtureRe.ReplaceAllString(name, "_"), "_")
+	if len(name) > 150 {
+		name = fmt.Sprintf("%s_%x", name[:140], sha1.Sum([]byte(name)))
+	}
+	return name + ".html"
+}
+
+// RecordTransport : saves the text pages retrieved through upstream to a directory
+type RecordTransport struct {
+	upstream http.RoundTripper
+	dir      string
+}
+
+// NewRecordTransport : initialize a transport that records pages to dir
+func NewRecordTransport(dir string, upstream http.RoundTripper) (*RecordTransport, error) {
+	if upstream == nil {
+		upstream = http.DefaultTransport
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &RecordTransport{
+		upstream: upstream,
+		dir:      dir,
+	}, nil
+}
+
+// RoundTrip : extends the RoundTrip API for usage as a colly transport
+func (t *RecordTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.upstream.RoundTrip(r)
+	if err != nil {
+		return resp, err
+	}
+	// Files such as videos are not recorded
+	contentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode != http.StatusOK ||
+		!(strings.Contains(contentType, "text") || strings.Contains(contentType, "json")) {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	file := filepath.Join(t.dir, FixtureName(r.Method, r.URL))
+	log.Debugf("Recording %v to %s", r.URL, file)
+	if err = ioutil.WriteFile(file, body, 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ReplayTransport : serves pages recorded by a RecordTransport without making any request
+type ReplayTransport struct {
+	dir string
+}
+
+// NewReplayTransport : initialize a transport that serves pages recorded to dir
+func NewReplayTransport(dir string) *ReplayTransport {
+	return &ReplayTransport{dir: dir}
+}
+
+// RoundTrip : extends the RoundTrip API for usage as a colly transport
+func (t *ReplayTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	file := filepath.Join(t.dir, FixtureName(r.Method, r.URL))
+	body, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no fixture recorded for %v", r.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", http.DetectContentType(body))
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}, nil
+}